@@ -22,9 +22,10 @@ type (
 	}
 
 	defaultTask struct {
-		model model.Model
-		cache cache.Cache
-		table string
+		model       model.Model
+		cache       cache.Cache
+		invalidator *cache.Invalidator // 写后删除缓存，延迟二次删除
+		table       string
 	}
 
 	Task struct {
@@ -86,12 +87,8 @@ func (m *defaultTask) Insert(ctx context.Context, task *Task) (res sql.Result, e
 		if err != nil {
 			return nil, err
 		}
-		go func() {
-			if err2 := m.cache.Del(ctx, m.primaryCachedKey(id)); err2 != nil {
-				// log
-			}
-		}()
-
+		// 删除失败由invalidator回调处理，不影响插入结果
+		_ = m.invalidator.Invalidate(ctx, m.primaryCachedKey(id))
 	}
 	return res, err
 }
@@ -170,7 +167,7 @@ func (m *defaultTask) Update(ctx context.Context, val, cond map[string]interface
 		}
 	}
 
-	return m.cache.Del(ctx, keys...)
+	return m.invalidator.Invalidate(ctx, keys...)
 }
 
 func (m *defaultTask) Delete(ctx context.Context, cond map[string]interface{}) (err error) {
@@ -190,5 +187,5 @@ func (m *defaultTask) Delete(ctx context.Context, cond map[string]interface{}) (
 		keys = append(keys, m.taskIdCachedKey(task.TaskId))
 	}
 
-	return m.cache.Del(ctx, keys...)
+	return m.invalidator.Invalidate(ctx, keys...)
 }
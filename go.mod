@@ -8,10 +8,15 @@ require (
 	github.com/go-redis/cache/v8 v8.3.1 // indirect
 	github.com/go-redis/redis/v8 v8.7.1
 	github.com/go-sql-driver/mysql v1.5.0 // indirect
-	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/json-iterator/go v1.1.9
+	github.com/klauspost/compress v1.11.4
+	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.1.3
+	github.com/spf13/viper v1.7.0
 	github.com/tal-tech/go-zero v1.1.5
+	github.com/vmihailenco/msgpack/v5 v5.1.0
 	github.com/xwb1989/sqlparser v0.0.0-20180606152119-120387863bf2
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+	go.etcd.io/bbolt v1.3.4
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/tools v0.0.0-20210115202250-e0d201561e39
 )
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	defaultAuditLimit    = 1000
+	defaultAuditScanSize = 100
+)
+
+var (
+	errAuditUnsupported = errors.New("cache: audit requires a remote store supporting Scan")
+	errAuditValue       = errors.New("cache: audit value must be a non-nil pointer")
+	errCodecMismatch    = errors.New("cache: codec mismatch")
+)
+
+type (
+	// Auditor 抽样比较缓存与数据源，发现不一致的缓存
+	Auditor struct {
+		cache      *store
+		valType    reflect.Type
+		loader     func(ctx context.Context, key string, v interface{}) error
+		sampleRate float64 // 抽样比例
+		limit      int     // 单次最多检查的key数量
+		repair     bool    // 删除不一致的缓存
+	}
+
+	// AuditReport 单次检查结果
+	AuditReport struct {
+		Scanned    int             `json:"scanned"`    // 遍历的key数量
+		Checked    int             `json:"checked"`    // 抽样检查的key数量
+		Errors     int             `json:"errors"`     // 读取或加载失败的key数量
+		Repaired   int             `json:"repaired"`   // 已删除的不一致缓存数量
+		Mismatches []AuditMismatch `json:"mismatches"` // 不一致的缓存
+	}
+
+	// AuditMismatch 不一致的缓存，值为json格式，不存在时为空
+	AuditMismatch struct {
+		Key    string `json:"key"`
+		Cached string `json:"cached"`
+		Loaded string `json:"loaded"`
+	}
+
+	AuditorOption func(a *Auditor)
+)
+
+// 设置抽样比例，取值(0, 1]，默认检查所有key
+func SetAuditSampleRate(rate float64) AuditorOption {
+	return func(a *Auditor) {
+		a.sampleRate = rate
+	}
+}
+
+// 设置单次最多检查的key数量
+func SetAuditLimit(n int) AuditorOption {
+	return func(a *Auditor) {
+		a.limit = n
+	}
+}
+
+// 删除不一致的缓存，下次读取时重新加载
+func EnableAuditRepair() AuditorOption {
+	return func(a *Auditor) {
+		a.repair = true
+	}
+}
+
+// NewAuditor 新建检查器，val为缓存值类型的指针，loader从数据源加载key对应的值，
+// 不存在时返回缓存的errNotFound
+func NewAuditor(c Cache, val interface{}, loader func(ctx context.Context, key string, v interface{}) error, opts ...AuditorOption) (*Auditor, error) {
+	s, ok := c.(*store)
+	if !ok {
+		return nil, errAuditUnsupported
+	}
+	if _, ok := s.rdb.(RemoteScanner); !ok {
+		return nil, errAuditUnsupported
+	}
+	t := reflect.TypeOf(val)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil, errAuditValue
+	}
+
+	a := &Auditor{
+		cache:      s,
+		valType:    t.Elem(),
+		loader:     loader,
+		sampleRate: 1,
+		limit:      defaultAuditLimit,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// Audit 遍历匹配pattern的缓存key并与数据源比较，pattern为不含命名空间前缀的glob模式
+func (a *Auditor) Audit(ctx context.Context, pattern string) (*AuditReport, error) {
+	c := a.cache
+	scanner := c.rdb.(RemoteScanner)
+	prefix := c.keyPrefix(ctx)
+	report := &AuditReport{}
+
+	var cursor uint64
+	for {
+		var keys []string
+		err := c.brk.do(func() (err error) {
+			keys, cursor, err = scanner.Scan(ctx, cursor, prefix+pattern, defaultAuditScanSize)
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+
+		for _, key := range keys {
+			report.Scanned++
+			if !strings.HasPrefix(key, prefix) || internalKey(strings.TrimPrefix(key, prefix)) {
+				continue
+			}
+			if a.sampleRate < 1 && rand.Float64() >= a.sampleRate {
+				continue
+			}
+			if report.Checked >= a.limit {
+				return report, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			report.Checked++
+			a.check(ctx, report, key, strings.TrimPrefix(key, prefix))
+		}
+		if cursor == 0 {
+			return report, nil
+		}
+	}
+}
+
+// 比较单个key，fullKey为缓存中的key，key为传给loader的key
+func (a *Auditor) check(ctx context.Context, report *AuditReport, fullKey, key string) {
+	c := a.cache
+
+	var data []byte
+	err := c.brk.do(func() (err error) {
+		data, err = c.rdb.Get(ctx, fullKey)
+		return err
+	})
+	if err == ErrRemoteNil {
+		// 遍历期间已过期
+		return
+	}
+	if err != nil {
+		report.Errors++
+		logx.Errorf("audit cache, key: %s, error: %v", fullKey, err)
+		return
+	}
+
+	cached := reflect.New(a.valType)
+	hasCached, err := c.decodeRaw(data, cached.Interface())
+	if err != nil {
+		// 无法解码，正常读取时会被删除，不计入不一致
+		report.Errors++
+		logx.Errorf("audit cache, decode key: %s, error: %v", fullKey, err)
+		return
+	}
+
+	loaded := reflect.New(a.valType)
+	err = a.loader(ctx, key, loaded.Interface())
+	hasLoaded := err == nil
+	if err != nil && err != c.errNotFound {
+		report.Errors++
+		logx.Errorf("audit cache, load key: %s, error: %v", key, err)
+		return
+	}
+
+	if hasCached == hasLoaded && (!hasCached || reflect.DeepEqual(cached.Interface(), loaded.Interface())) {
+		return
+	}
+
+	mismatch := AuditMismatch{Key: key}
+	if hasCached {
+		mismatch.Cached = auditString(cached.Interface())
+	}
+	if hasLoaded {
+		mismatch.Loaded = auditString(loaded.Interface())
+	}
+	report.Mismatches = append(report.Mismatches, mismatch)
+	logx.Errorf("audit cache mismatch, key: %s, cached: %s, loaded: %s", key, mismatch.Cached, mismatch.Loaded)
+
+	if a.repair {
+		if err := c.del(ctx, fullKey); err != nil {
+			logx.Errorf("audit cache, repair key: %s, error: %v", fullKey, err)
+			return
+		}
+		report.Repaired++
+	}
+}
+
+// 解码缓存原始数据，不修改缓存，NotFound占位返回false
+func (c *store) decodeRaw(data []byte, v interface{}) (bool, error) {
+	if bytes.Equal(data, notFoundPlaceholder) {
+		return false, nil
+	}
+	value, _ := unwrapEntry(data)
+	value, err := decompressValue(value)
+	if err != nil {
+		return false, err
+	}
+	ok, err := decodeValue(c.codec, value, v)
+	if !ok {
+		return false, errCodecMismatch
+	}
+	return err == nil, err
+}
+
+// 锁、标签集合与版本号等内部使用的key
+func internalKey(key string) bool {
+	for _, prefix := range []string{lockKeyPrefix, tagKeyPrefix, keyTagsPrefix, versionKeyPrefix} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func auditString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,278 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+var errBatchDest = errors.New("cache: dest must be a pointer to map[string]T or []T")
+
+// 批量查询的目标容器
+type batchDest struct {
+	dest     reflect.Value // map或slice
+	elemType reflect.Type  // 元素类型T
+	baseType reflect.Type  // T去掉指针后的类型
+	values   map[string]reflect.Value
+}
+
+func newBatchDest(dest interface{}) (*batchDest, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, errBatchDest
+	}
+	v = v.Elem()
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errBatchDest
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	case reflect.Slice:
+	default:
+		return nil, errBatchDest
+	}
+
+	elemType := v.Type().Elem()
+	baseType := elemType
+	if baseType.Kind() == reflect.Ptr {
+		baseType = baseType.Elem()
+	}
+	return &batchDest{
+		dest:     v,
+		elemType: elemType,
+		baseType: baseType,
+		values:   make(map[string]reflect.Value),
+	}, nil
+}
+
+// 新建用于解码的指针
+func (b *batchDest) newValue() reflect.Value {
+	return reflect.New(b.baseType)
+}
+
+// 保存解码后的指针
+func (b *batchDest) put(key string, ptr reflect.Value) {
+	if b.elemType.Kind() == reflect.Ptr {
+		b.values[key] = ptr
+	} else {
+		b.values[key] = ptr.Elem()
+	}
+}
+
+// 保存query返回的值，支持T与*T
+func (b *batchDest) putLoaded(key string, val interface{}) error {
+	v := reflect.ValueOf(val)
+	switch {
+	case !v.IsValid(), v.Kind() == reflect.Ptr && v.IsNil():
+		return fmt.Errorf("cache: loaded value of key %s is nil", key)
+	case v.Type().AssignableTo(b.elemType):
+		b.values[key] = v
+	case v.Kind() == reflect.Ptr && v.Type().Elem().AssignableTo(b.elemType):
+		b.values[key] = v.Elem()
+	case b.elemType.Kind() == reflect.Ptr && v.Type().AssignableTo(b.baseType):
+		ptr := reflect.New(b.baseType)
+		ptr.Elem().Set(v)
+		b.values[key] = ptr
+	default:
+		return fmt.Errorf("cache: loaded value of key %s is %s, want %s", key, v.Type(), b.elemType)
+	}
+	return nil
+}
+
+// 按keys顺序写入结果
+func (b *batchDest) flush(keys []string) {
+	if b.dest.Kind() == reflect.Map {
+		for _, key := range keys {
+			if v, ok := b.values[key]; ok {
+				b.dest.SetMapIndex(reflect.ValueOf(key), v)
+			}
+		}
+		return
+	}
+	for _, key := range keys {
+		if v, ok := b.values[key]; ok {
+			b.dest.Set(reflect.Append(b.dest, v))
+		}
+	}
+}
+
+func (c *store) GetMany(ctx context.Context, keys []string, dest interface{}) error {
+	b, err := newBatchDest(dest)
+	if err != nil {
+		return err
+	}
+	if controlFrom(ctx).has(controlBypass) {
+		return nil
+	}
+	keys = uniqueKeys(keys)
+	if _, err := c.getMany(ctx, c.keyPrefix(ctx), keys, b); err != nil {
+		return err
+	}
+	b.flush(keys)
+	return nil
+}
+
+func (c *store) TakeMany(ctx context.Context, keys []string, dest interface{}, query func(ctx context.Context, keys []string) (map[string]interface{}, error)) error {
+	b, err := newBatchDest(dest)
+	if err != nil {
+		return err
+	}
+	keys = uniqueKeys(keys)
+	ctl := controlFrom(ctx)
+	prefix := c.keyPrefix(ctx)
+	missing := keys
+	if !ctl.has(controlBypass) {
+		if missing, err = c.getMany(ctx, prefix, keys, b); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		loaded, err := query(ctx, missing)
+		if err != nil && err != c.errNotFound {
+			c.stat.incr(&c.stat.loaderErrors)
+			return err
+		}
+		if ctl.has(controlBypass) || ctl.has(controlNoWriteBack) {
+			// 只返回加载的数据，不回写缓存
+			for key, val := range loaded {
+				if err := b.putLoaded(key, val); err != nil {
+					return err
+				}
+			}
+		} else if err := c.setMany(ctx, prefix, missing, loaded, b); err != nil {
+			return err
+		}
+	}
+
+	b.flush(keys)
+	return nil
+}
+
+// 依次查询本地缓存与redis，返回未命中的key，NotFound占位的key不返回，
+// 缓存中的key为prefix+key
+func (c *store) getMany(ctx context.Context, prefix string, keys []string, b *batchDest) (missing []string, err error) {
+	var remote []string
+	skipLocal := controlFrom(ctx).has(controlSkipLocal)
+	for _, key := range keys {
+		if c.enableMdb && !skipLocal {
+			if data, exist := c.mdb.Get(prefix + key); exist {
+				ptr := b.newValue()
+				_, err := c.processCache(ctx, localStore, prefix+key, data, ptr.Interface())
+				switch err {
+				case nil:
+					b.put(key, ptr)
+					continue
+				case errPlaceholder:
+					continue
+				}
+			}
+		}
+		remote = append(remote, key)
+	}
+
+	if len(remote) == 0 {
+		return nil, nil
+	}
+	if c.rdb == nil {
+		atomic.AddUint64(&c.stat.misses, uint64(len(remote)))
+		return remote, nil
+	}
+
+	fullKeys := make([]string, len(remote))
+	for i, key := range remote {
+		fullKeys[i] = prefix + key
+	}
+	var values [][]byte
+	err = c.brk.do(func() (err error) {
+		values, err = c.rdb.MGet(ctx, fullKeys...)
+		return err
+	})
+	if isBreakerOpen(err) {
+		atomic.AddUint64(&c.stat.misses, uint64(len(remote)))
+		return remote, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range remote {
+		if values[i] == nil {
+			c.stat.incr(&c.stat.misses)
+			missing = append(missing, key)
+			continue
+		}
+		ptr := b.newValue()
+		_, err := c.processCache(ctx, redisStore, fullKeys[i], values[i], ptr.Interface())
+		switch err {
+		case nil:
+			b.put(key, ptr)
+		case errPlaceholder:
+		default:
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
+
+// 批量回写缓存，loaded中不存在的key写入NotFound占位
+func (c *store) setMany(ctx context.Context, prefix string, keys []string, loaded map[string]interface{}, b *batchDest) error {
+	type entry struct {
+		data   []byte
+		expire time.Duration
+	}
+	entries := make(map[string]entry, len(keys))
+	for _, key := range keys {
+		val, ok := loaded[key]
+		if !ok {
+			entries[prefix+key] = entry{data: notFoundPlaceholder, expire: c.withJitter(c.notFoundExpiration)}
+			continue
+		}
+		if err := b.putLoaded(key, val); err != nil {
+			return err
+		}
+		data, expire, err := c.marshalCache(key, val, c.expiration)
+		if err != nil {
+			return err
+		}
+		entries[prefix+key] = entry{data: data, expire: expire}
+	}
+
+	for key, e := range entries {
+		c.unpinHotKeys(key)
+		c.setLocal(ctx, key, e.data, e.expire)
+	}
+
+	if c.rdb == nil {
+		return nil
+	}
+	err := c.brk.do(func() error {
+		return c.rdb.Pipeline(ctx, func(pipe RemotePipeline) {
+			for key, e := range entries {
+				pipe.Set(key, e.data, e.expire)
+			}
+		})
+	})
+	if isBreakerOpen(err) {
+		return nil
+	}
+	return err
+}
+
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		res = append(res, key)
+	}
+	return res
+}
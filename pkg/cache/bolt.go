@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultBoltPurgeInterval = time.Minute
+
+var (
+	boltBucket    = []byte("cache")
+	boltTagBucket = []byte("cache_tags") // 每个标签集合一个子bucket，成员对应过期时间戳
+)
+
+type (
+	// BoltRemote 基于bbolt的磁盘二级缓存，用于单机部署
+	BoltRemote struct {
+		db   *bolt.DB
+		done chan struct{}
+		wg   sync.WaitGroup
+	}
+
+	boltPipeline struct {
+		ops []func(tx *bolt.Tx) error
+	}
+)
+
+// NewBoltRemote 打开path处的bbolt数据库作为二级缓存，后台定期清理过期数据
+func NewBoltRemote(path string) (*BoltRemote, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltBucket, boltTagBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	r := &BoltRemote{
+		db:   db,
+		done: make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.purgeLoop(defaultBoltPurgeInterval)
+	return r, nil
+}
+
+// 存储格式: [8字节过期时间戳][value]，时间戳为0表示不过期
+func encodeBoltValue(val []byte, expire time.Duration) []byte {
+	buf := make([]byte, 8, 8+len(val))
+	if expire > 0 {
+		binary.BigEndian.PutUint64(buf, uint64(time.Now().Add(expire).UnixNano()))
+	}
+	return append(buf, val...)
+}
+
+func decodeBoltValue(data []byte, now time.Time) ([]byte, bool) {
+	if len(data) < 8 || boltExpired(data, now) {
+		return nil, false
+	}
+	// bbolt返回的数据只在事务内有效
+	return append([]byte(nil), data[8:]...), true
+}
+
+func boltExpired(data []byte, now time.Time) bool {
+	at := int64(binary.BigEndian.Uint64(data))
+	return at > 0 && now.UnixNano() > at
+}
+
+// 删除key及同名的标签集合
+func boltDelete(tx *bolt.Tx, key []byte) error {
+	if err := tx.Bucket(boltBucket).Delete(key); err != nil {
+		return err
+	}
+	tags := tx.Bucket(boltTagBucket)
+	if tags.Bucket(key) == nil {
+		return nil
+	}
+	return tags.DeleteBucket(key)
+}
+
+func (r *BoltRemote) Get(ctx context.Context, key string) (val []byte, err error) {
+	err = r.db.View(func(tx *bolt.Tx) error {
+		var ok bool
+		val, ok = decodeBoltValue(tx.Bucket(boltBucket).Get([]byte(key)), time.Now())
+		if !ok {
+			return ErrRemoteNil
+		}
+		return nil
+	})
+	return val, err
+}
+
+func (r *BoltRemote) Set(ctx context.Context, key string, val []byte, expire time.Duration) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), encodeBoltValue(val, expire))
+	})
+}
+
+func (r *BoltRemote) Del(ctx context.Context, keys ...string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if err := boltDelete(tx, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BoltRemote) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	res := make([][]byte, len(keys))
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		now := time.Now()
+		for i, key := range keys {
+			if val, ok := decodeBoltValue(b.Get([]byte(key)), now); ok {
+				res[i] = val
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (r *BoltRemote) Pipeline(ctx context.Context, fn func(pipe RemotePipeline)) error {
+	pipe := &boltPipeline{}
+	fn(pipe)
+	return r.db.Update(func(tx *bolt.Tx) error {
+		for _, op := range pipe.ops {
+			if err := op(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BoltRemote) SetNX(ctx context.Context, key string, val []byte, expire time.Duration) (ok bool, err error) {
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if _, exist := decodeBoltValue(b.Get([]byte(key)), time.Now()); exist {
+			return nil
+		}
+		ok = true
+		return b.Put([]byte(key), encodeBoltValue(val, expire))
+	})
+	return ok, err
+}
+
+func (r *BoltRemote) DelIfEqual(ctx context.Context, key string, val []byte) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if cur, ok := decodeBoltValue(b.Get([]byte(key)), time.Now()); ok && bytes.Equal(cur, val) {
+			return b.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+func (r *BoltRemote) Incr(ctx context.Context, key string) (n int64, err error) {
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if cur, ok := decodeBoltValue(b.Get([]byte(key)), time.Now()); ok {
+			if n, err = strconv.ParseInt(string(cur), 10, 64); err != nil {
+				return err
+			}
+		}
+		n++
+		return b.Put([]byte(key), encodeBoltValue([]byte(strconv.FormatInt(n, 10)), 0))
+	})
+	return n, err
+}
+
+func (r *BoltRemote) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	var keys []string
+	err := r.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		return tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			if _, ok := decodeBoltValue(v, now); ok {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	// bbolt按key排序遍历
+	return scanKeys(keys, cursor, match, count)
+}
+
+func (r *BoltRemote) TagAdd(ctx context.Context, tagKeys []string, member string, expire time.Duration) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		val := encodeBoltValue(nil, expire)
+		for _, tagKey := range tagKeys {
+			b, err := tx.Bucket(boltTagBucket).CreateBucketIfNotExists([]byte(tagKey))
+			if err != nil {
+				return err
+			}
+			if err := purgeBoltBucket(b, now); err != nil {
+				return err
+			}
+			if err := b.Put([]byte(member), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BoltRemote) TagMembers(ctx context.Context, tagKey string) ([]string, error) {
+	var res []string
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltTagBucket).Bucket([]byte(tagKey))
+		if b == nil {
+			return nil
+		}
+		now := time.Now()
+		return b.ForEach(func(k, v []byte) error {
+			if len(v) >= 8 && !boltExpired(v, now) {
+				res = append(res, string(k))
+			}
+			return nil
+		})
+	})
+	return res, err
+}
+
+// Close 停止后台清理并关闭数据库
+func (r *BoltRemote) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return r.db.Close()
+}
+
+func (r *BoltRemote) purgeLoop(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			if err := r.purge(); err != nil {
+				logx.Errorf("purge expired bolt cache, error: %v", err)
+			}
+		}
+	}
+}
+
+// 删除过期数据与过期的标签成员，删除成员为空的标签集合
+func (r *BoltRemote) purge() error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		if err := purgeBoltBucket(tx.Bucket(boltBucket), now); err != nil {
+			return err
+		}
+
+		// 遍历期间不能修改bucket，先收集标签集合
+		tags := tx.Bucket(boltTagBucket)
+		var names [][]byte
+		if err := tags.ForEach(func(k, v []byte) error {
+			names = append(names, append([]byte(nil), k...))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, name := range names {
+			b := tags.Bucket(name)
+			if err := purgeBoltBucket(b, now); err != nil {
+				return err
+			}
+			if k, _ := b.Cursor().First(); k != nil {
+				continue
+			}
+			if err := tags.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func purgeBoltBucket(b *bolt.Bucket, now time.Time) error {
+	var expired [][]byte
+	if err := b.ForEach(func(k, v []byte) error {
+		if _, ok := decodeBoltValue(v, now); !ok {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *boltPipeline) Set(key string, val []byte, expire time.Duration) {
+	data := encodeBoltValue(val, expire)
+	p.ops = append(p.ops, func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), data)
+	})
+}
+
+func (p *boltPipeline) Del(keys ...string) {
+	p.ops = append(p.ops, func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if err := boltDelete(tx, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
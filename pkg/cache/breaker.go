@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/tal-tech/go-zero/core/breaker"
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+// 二级缓存熔断器，熔断期间读取当作未命中，跳过写入
+type remoteBreaker struct {
+	brk     breaker.Breaker
+	name    string
+	open    int32
+	rejects uint64
+}
+
+// 禁用二级缓存熔断
+func DisableBreaker() Option {
+	return func(s *store) {
+		s.disableBreaker = true
+	}
+}
+
+func newRemoteBreaker(name string) *remoteBreaker {
+	if name == "" {
+		return &remoteBreaker{brk: breaker.NewBreaker(), name: "cache"}
+	}
+	return &remoteBreaker{brk: breaker.NewBreaker(breaker.WithName(name)), name: name}
+}
+
+// 执行二级缓存操作，熔断时返回breaker.ErrServiceUnavailable
+func (b *remoteBreaker) do(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+
+	err := b.brk.DoWithAcceptable(fn, acceptableRemoteError)
+	if err == breaker.ErrServiceUnavailable {
+		atomic.AddUint64(&b.rejects, 1)
+		if atomic.CompareAndSwapInt32(&b.open, 0, 1) {
+			logx.Errorf("cache breaker open, name: %s, skip remote store", b.name)
+		}
+		return err
+	}
+	// 半开状态下放行的请求成功后关闭
+	if acceptableRemoteError(err) && atomic.CompareAndSwapInt32(&b.open, 1, 0) {
+		logx.Infof("cache breaker closed, name: %s", b.name)
+	}
+	return err
+}
+
+// 熔断器是否打开
+func (b *remoteBreaker) isOpen() bool {
+	return b != nil && atomic.LoadInt32(&b.open) == 1
+}
+
+func (b *remoteBreaker) rejected() uint64 {
+	if b == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&b.rejects)
+}
+
+func acceptableRemoteError(err error) bool {
+	return err == nil || err == ErrRemoteNil || err == context.Canceled
+}
+
+func isBreakerOpen(err error) bool {
+	return err == breaker.ErrServiceUnavailable
+}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/patrickmn/go-cache"
 	"github.com/tal-tech/go-zero/core/logx"
 	"golang.org/x/sync/singleflight"
 )
@@ -19,6 +21,9 @@ const (
 	redisStore
 )
 
+// 后台刷新使用的singleflight key前缀
+const refreshKeyPrefix = "refresh#"
+
 // indicates there is no such value associate with the key
 var (
 	notFoundPlaceholder = []byte("*")
@@ -28,7 +33,8 @@ var (
 	defaultExpiration         = time.Minute * 60
 	defaultNotFoundExpiration = time.Minute * 2
 
-	stores = make(map[string]*store)
+	storesMu sync.RWMutex
+	stores   = make(map[string][]*store) // 同名缓存可能有多个，均需清除本地缓存
 )
 
 type (
@@ -37,22 +43,73 @@ type (
 		Get(ctx context.Context, key string, val interface{}) error
 		Set(ctx context.Context, key string, val interface{}) error
 		Del(ctx context.Context, key ...string) error
+		// TakeWithExpire 同Take，回写缓存时使用指定的缓存时间
+		TakeWithExpire(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error
+		// SetWithExpire 同Set，使用指定的缓存时间
+		SetWithExpire(ctx context.Context, key string, val interface{}, expire time.Duration) error
+		// TakeMany 批量获取缓存，dest为map[string]T或[]T的指针，
+		// 未命中的key调用query批量加载，query未返回的key缓存为NotFound
+		TakeMany(ctx context.Context, keys []string, dest interface{}, query func(ctx context.Context, keys []string) (map[string]interface{}, error)) error
+		// GetMany 批量获取缓存，只写入命中的值
+		GetMany(ctx context.Context, keys []string, dest interface{}) error
+		// SetWithTags 同Set，并将key记录到标签集合
+		SetWithTags(ctx context.Context, key string, val interface{}, tags ...string) error
+		// TakeWithTags 同Take，并将key记录到标签集合，命中缓存时同样记录
+		TakeWithTags(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, tags ...string) error
+		// InvalidateTags 删除标签集合中的所有缓存，二级缓存不支持标签时返回ErrTagsUnsupported
+		InvalidateTags(ctx context.Context, tags ...string) error
+		// Stats 返回缓存统计快照
+		Stats() Stats
+		// HotKeys 返回当前热点key，未启用热点探测时返回nil
+		HotKeys() []HotKey
+		// BumpVersion 更新命名空间版本号，命名空间下的所有缓存失效，返回新版本号
+		BumpVersion(ctx context.Context) (int64, error)
 	}
 
 	Option func(s *store)
 
 	store struct {
 		name               string
-		mdb                *cache.Cache  // 一级缓存
-		rdb                redis.Cmdable // 二级缓存
-		enableMdb          bool          // default true
+		mdb                LocalStore  // 一级缓存
+		rdb                RemoteStore // 二级缓存
+		enableMdb          bool        // default true
 		g                  singleflight.Group
 		errNotFound        error
 		expiration         time.Duration
 		notFoundExpiration time.Duration
+		codec              Codec
+		softExpiration     time.Duration // 软过期时间，0表示不启用
+		staleGrace         time.Duration // 过期后保留时间，0表示不启用
+		onStale            func(ctx context.Context, key string, err error)
+		hot                *hotKeyDetector
+		jitterRatio        float64       // 缓存时间随机浮动比例
+		jitterRange        time.Duration // 缓存时间随机增加范围
+		stat               stat
+		localMaxEntries    int
+		localMaxBytes      int64
+		enableLock         bool           // 跨实例合并加载
+		lockExpiration     time.Duration  // 锁过期时间
+		lockWaitTimeout    time.Duration  // 等待持锁实例的超时时间
+		namespace          string         // key命名空间
+		schemaVersion      int            // 缓存值结构版本
+		ver                versionState   // 命名空间版本号
+		compression        Compression    // 压缩算法
+		compressThreshold  int            // 超过该大小时压缩
+		bigKeyLimit        int            // 大key告警阈值
+		brk                *remoteBreaker // 二级缓存熔断器
+		disableBreaker     bool
+		localTags          RemoteStore // 只使用本地缓存时的标签集合
+		tagged             int32       // 是否使用过标签，使用过时重写缓存需延长标签集合
 	}
 )
 
+// 设置缓存名称，同名缓存跨实例同步清除本地缓存，一般使用表名
+func SetName(name string) Option {
+	return func(s *store) {
+		s.name = name
+	}
+}
+
 // 设置缓存时间
 func SetExpiration(d time.Duration) Option {
 	return func(s *store) {
@@ -67,6 +124,96 @@ func SetNotFoundExpiration(d time.Duration) Option {
 	}
 }
 
+// 设置缓存时间随机浮动比例，如0.1表示在±10%内浮动，避免大量缓存同时过期
+func SetExpirationJitter(ratio float64) Option {
+	return func(s *store) {
+		s.jitterRatio = ratio
+	}
+}
+
+// 设置缓存时间随机增加范围，缓存时间在[d, d+jitter)内随机
+func SetExpirationJitterRange(jitter time.Duration) Option {
+	return func(s *store) {
+		s.jitterRange = jitter
+	}
+}
+
+// 设置缓存值编解码器，默认JSONCodec
+func SetCodec(codec Codec) Option {
+	return func(s *store) {
+		s.codec = codec
+	}
+}
+
+// 启用过期重验证，超过软过期时间后Take直接返回旧值并在后台刷新，
+// 超过缓存时间后仍然阻塞加载
+func EnableStaleWhileRevalidate(softExpiration time.Duration) Option {
+	return func(s *store) {
+		s.softExpiration = softExpiration
+	}
+}
+
+// 启用跨实例合并加载，缓存未命中时只有拿到redis锁的实例调用query，
+// 其他实例等待其写入缓存，持锁实例加载失败时返回ErrLoadFailed，超过waitTimeout后自行加载
+func EnableDistributedLock(lockExpiration, waitTimeout time.Duration) Option {
+	return func(s *store) {
+		s.enableLock = true
+		if lockExpiration > 0 {
+			s.lockExpiration = lockExpiration
+		}
+		if waitTimeout > 0 {
+			s.lockWaitTimeout = waitTimeout
+		}
+	}
+}
+
+// 启用热点key探测，window时间内访问次数达到threshold的key在本地缓存ttl时间，
+// 禁用本地缓存时同样生效
+func EnableHotKeyDetection(window time.Duration, threshold uint64, ttl time.Duration) Option {
+	return func(s *store) {
+		s.hot = newHotKeyDetector(window, threshold, ttl, 1)
+	}
+}
+
+// 设置热点key探测的采样率，每n次访问统计一次，需在EnableHotKeyDetection之后设置
+func SetHotKeySampleRate(n uint64) Option {
+	return func(s *store) {
+		if s.hot != nil && n > 0 {
+			s.hot.sampleRate = n
+		}
+	}
+}
+
+// 设置一级缓存实现，默认为LRU
+func SetLocalStore(local LocalStore) Option {
+	return func(s *store) {
+		s.mdb = local
+	}
+}
+
+// 设置默认一级缓存的最大条目数与最大内存占用
+func SetLocalCacheLimit(maxEntries int, maxBytes int64) Option {
+	return func(s *store) {
+		s.localMaxEntries = maxEntries
+		s.localMaxBytes = maxBytes
+	}
+}
+
+// 启用加载失败时返回旧值，缓存过期后额外保留grace时间，
+// 期间query返回errNotFound以外的错误时Take返回旧值
+func EnableStaleOnError(grace time.Duration) Option {
+	return func(s *store) {
+		s.staleGrace = grace
+	}
+}
+
+// 设置返回旧值时的回调，用于标记降级
+func OnStale(fn func(ctx context.Context, key string, err error)) Option {
+	return func(s *store) {
+		s.onStale = fn
+	}
+}
+
 // 禁用本地缓存
 func DisableLocalCache() Option {
 	return func(s *store) {
@@ -74,164 +221,459 @@ func DisableLocalCache() Option {
 	}
 }
 
+// New 使用redis作为二级缓存，client为nil时只使用本地缓存
 func New(client redis.Cmdable, errNotFound error, opts ...Option) Cache {
+	var remote RemoteStore
+	if client != nil {
+		remote = NewRedisRemote(client)
+	}
+	return NewWithRemote(remote, errNotFound, opts...)
+}
+
+// NewWithRemote 使用指定的二级缓存，remote为nil时只使用本地缓存
+func NewWithRemote(remote RemoteStore, errNotFound error, opts ...Option) Cache {
 	s := &store{
 		enableMdb:          true,
 		errNotFound:        errNotFound,
 		expiration:         defaultExpiration,
 		notFoundExpiration: defaultNotFoundExpiration,
+		codec:              JSONCodec{},
+		localMaxEntries:    defaultLocalMaxEntries,
+		localMaxBytes:      defaultLocalMaxBytes,
+		lockExpiration:     defaultLockExpiration,
+		lockWaitTimeout:    defaultLockWaitTimeout,
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	s.mdb = cache.New(s.expiration, s.expiration*2)
-	if client != nil {
-		s.rdb = client
+	if s.mdb == nil {
+		s.mdb = NewLRUStore(s.localMaxEntries, s.localMaxBytes)
+	}
+	s.rdb = remote
+	if remote == nil {
+		s.localTags = NewMemoryRemote()
+	}
+	if remote != nil && !s.disableBreaker {
+		s.brk = newRemoteBreaker(s.name)
+	}
+	if s.name != "" {
+		registerStore(s)
 	}
 	return s
 }
 
+func registerStore(s *store) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	stores[s.name] = append(stores[s.name], s)
+}
+
+func allStores() []*store {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	var res []*store
+	for _, named := range stores {
+		res = append(res, named...)
+	}
+	return res
+}
+
+func lookupStores(name string) []*store {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	return append([]*store(nil), stores[name]...)
+}
+
 func (c *store) Take(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error) error {
-	_, err, _ := c.g.Do(key, func() (interface{}, error) {
-		if err := c.doGetCache(ctx, key, val); err != nil {
-			switch err {
-			case errPlaceholder:
-				return nil, c.errNotFound
-			case c.errNotFound:
-
-				err := query(ctx, val)
-
-				if err != nil {
-					if err == c.errNotFound {
-						if err2 := c.setCacheWithNotFound(ctx, key); err2 != nil {
-							// set cache err
-							return nil, err2
-						}
-						return nil, err
-					}
-					// db query err
-					return nil, err
+	return c.TakeWithExpire(ctx, key, val, query, c.expiration)
+}
+
+func (c *store) TakeWithExpire(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error {
+	return c.take(ctx, c.keyPrefix(ctx)+key, val, query, expire)
+}
+
+func (c *store) take(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error {
+	ctl := controlFrom(ctx)
+	if ctl.has(controlBypass) {
+		return query(ctx, val)
+	}
+
+	// 只有执行加载的调用者会运行该函数，其他调用者从返回的编码值解码，
+	// 避免共享同一个val
+	var leader bool
+	res, err, _ := c.g.Do(flightKey(ctx, key), func() (interface{}, error) {
+		leader = true
+		data, stale, err := c.getCache(ctx, key, val)
+		switch err {
+		case nil:
+			if stale {
+				// 软过期，返回旧值并后台刷新
+				c.refreshAsync(key, val, query, expire)
+			}
+			return data, nil
+		case errPlaceholder:
+			return nil, c.errNotFound
+		case c.errNotFound:
+			load := c.load
+			if locker, ok := c.rdb.(RemoteLocker); ok && c.enableLock && !ctl.has(controlNoWriteBack) && !c.brk.isOpen() {
+				load = func(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error {
+					return c.loadWithLock(ctx, locker, key, val, query, expire)
 				}
-				// rewrite cache
-				if err := c.setCache(ctx, key, val); err != nil {
-					return nil, err
+			}
+			if err := load(ctx, key, val, query, expire); err != nil {
+				if err != c.errNotFound && c.serveStale(ctx, key, val, err) {
+					return encodeValue(c.codec, val)
 				}
-			default:
 				return nil, err
 			}
+			return encodeValue(c.codec, val)
+		default:
+			return nil, err
 		}
-		return nil, nil
 	})
+	if err != nil || leader {
+		return err
+	}
+
+	// 共享其他调用者的结果
+	c.stat.incr(&c.stat.sharedCalls)
+	_, err = c.decodeRaw(res.([]byte), val)
 	return err
 }
 
+// 调用query加载数据并回写缓存
+func (c *store) load(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error {
+	noWriteBack := controlFrom(ctx).has(controlNoWriteBack)
+	if err := query(ctx, val); err != nil {
+		if err != c.errNotFound {
+			c.stat.incr(&c.stat.loaderErrors)
+		}
+		if err == c.errNotFound && !noWriteBack {
+			if err2 := c.setCacheWithNotFound(ctx, key); err2 != nil {
+				// set cache err
+				return err2
+			}
+		}
+		// db query err
+		return err
+	}
+	if noWriteBack {
+		return nil
+	}
+	// rewrite cache
+	return c.setCache(ctx, key, val, expire)
+}
+
+// 后台刷新软过期的缓存，同一个key同时只有一个刷新任务
+func (c *store) refreshAsync(key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) {
+	fresh := reflect.New(reflect.TypeOf(val).Elem()).Interface()
+	go func() {
+		_, err, _ := c.g.Do(refreshKeyPrefix+key, func() (interface{}, error) {
+			return nil, c.load(context.Background(), key, fresh, query, expire)
+		})
+		if err != nil && err != c.errNotFound {
+			logx.Errorf("refresh stale cache, key: %s, error: %v", key, err)
+		}
+	}()
+}
+
 func (c *store) Get(ctx context.Context, key string, val interface{}) error {
-	return c.doGetCache(ctx, key, val)
+	if controlFrom(ctx).has(controlBypass) {
+		return c.errNotFound
+	}
+	return c.doGetCache(ctx, c.keyPrefix(ctx)+key, val)
 }
 
 func (c *store) Set(ctx context.Context, key string, val interface{}) error {
-	return c.setCache(ctx, key, val)
+	return c.SetWithExpire(ctx, key, val, c.expiration)
+}
+
+func (c *store) SetWithExpire(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	return c.set(ctx, c.keyPrefix(ctx)+key, val, expire)
+}
+
+func (c *store) set(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	if controlFrom(ctx).has(controlBypass) {
+		return nil
+	}
+	if err := c.setCache(ctx, key, val, expire); err != nil {
+		return err
+	}
+	publishDeleteLocalCache(ctx, c.name, key)
+	return nil
 }
 
 func (c *store) Del(ctx context.Context, key ...string) error {
+	if len(key) == 0 {
+		return nil
+	}
+	return c.del(ctx, c.fullKeys(ctx, key)...)
+}
+
+func (c *store) del(ctx context.Context, key ...string) error {
 	c.deleteLocalCache(key...)
 	if c.rdb != nil {
-		return c.rdb.Del(ctx, key...).Err()
+		if err := c.brk.do(func() error {
+			return c.rdb.Del(ctx, key...)
+		}); err != nil {
+			return err
+		}
 	}
+	publishDeleteLocalCache(ctx, c.name, key...)
 	return nil
 }
 
+func (c *store) Stats() Stats {
+	stats := c.stat.snapshot(c.name)
+	stats.BreakerOpen = c.brk.isOpen()
+	stats.BreakerRejects = c.brk.rejected()
+	if c.enableMdb {
+		local := c.mdb.Stats()
+		stats.LocalEntries = local.Entries
+		stats.LocalBytes = local.Bytes
+		stats.LocalEvictions = local.Evictions
+	}
+	return stats
+}
+
 func (c *store) deleteLocalCache(key ...string) {
 	if c.enableMdb {
 		for _, k := range key {
 			c.mdb.Delete(k)
 		}
 	}
+	c.unpinHotKeys(key...)
 }
 
-func (c *store) doGetCache(ctx context.Context, key string, val interface{}) (err error) {
-	if c.enableMdb {
-		value, exist := c.mdb.Get(key)
-		if exist {
-			if v, ok := value.([]byte); ok {
-				return c.processCache(ctx, localStore, key, v, val)
-			}
+// 清除固定到本地的热点key
+func (c *store) unpinHotKeys(key ...string) {
+	if c.hot != nil {
+		for _, k := range key {
+			c.hot.local.Delete(k)
+		}
+	}
+}
+
+func (c *store) HotKeys() []HotKey {
+	if c.hot == nil {
+		return nil
+	}
+	return c.hot.hotKeys()
+}
+
+func (c *store) doGetCache(ctx context.Context, key string, val interface{}) error {
+	_, _, err := c.getCache(ctx, key, val)
+	return err
+}
+
+// 读取缓存，data为缓存原始数据，stale表示缓存已软过期
+func (c *store) getCache(ctx context.Context, key string, val interface{}) (data []byte, stale bool, err error) {
+	skipLocal := controlFrom(ctx).has(controlSkipLocal)
+	if c.enableMdb && !skipLocal {
+		if data, exist := c.mdb.Get(key); exist {
+			stale, err := c.processCache(ctx, localStore, key, data, val)
+			return data, stale, err
+		}
+	}
+
+	var hot bool
+	if c.hot != nil {
+		hot = c.hot.record(key)
+		if data, exist := c.hot.local.Get(key); exist && !skipLocal {
+			stale, err := c.processCache(ctx, localStore, key, data, val)
+			return data, stale, err
 		}
 	}
 
 	if c.rdb != nil {
-		data, err := c.rdb.Get(ctx, key).Bytes()
+		var data []byte
+		err := c.brk.do(func() (err error) {
+			data, err = c.rdb.Get(ctx, key)
+			return err
+		})
 		if err != nil {
-			if err == redis.Nil {
-				return c.errNotFound
+			// 熔断时当作未命中，直接调用query
+			if err == ErrRemoteNil || isBreakerOpen(err) {
+				c.stat.incr(&c.stat.misses)
+				return nil, false, c.errNotFound
+			}
+			return nil, false, err
+		}
+		stale, err := c.processCache(ctx, redisStore, key, data, val)
+		if hot && (err == nil || err == errPlaceholder) {
+			// 热点key固定到本地缓存，减轻redis压力
+			_, meta := unwrapEntry(data)
+			if ttl, ok := meta.localExpire(c.hot.ttl, time.Now()); ok {
+				c.hot.local.Set(key, data, ttl)
 			}
-			return err
 		}
-		return c.processCache(ctx, redisStore, key, data, val)
+		return data, stale, err
 	}
 
-	return c.errNotFound
+	c.stat.incr(&c.stat.misses)
+	return nil, false, c.errNotFound
 }
 
-func (c *store) setCache(ctx context.Context, key string, val interface{}) error {
-	marshal, err := json.Marshal(val)
+func (c *store) setCache(ctx context.Context, key string, val interface{}, expire time.Duration) error {
+	marshal, expire, err := c.marshalCache(key, val, expire)
 	if err != nil {
 		return err
 	}
 
-	if c.enableMdb {
-		c.mdb.Set(key, marshal, c.expiration)
+	c.unpinHotKeys(key)
+	c.setLocal(ctx, key, marshal, expire)
+	if err := c.setRemote(ctx, key, marshal, expire); err != nil {
+		return err
 	}
+	return c.retag(ctx, key, expire)
+}
 
-	if c.rdb != nil {
-		return c.rdb.Set(ctx, key, marshal, c.expiration).Err()
+// 写入二级缓存，熔断时跳过
+func (c *store) setRemote(ctx context.Context, key string, data []byte, expire time.Duration) error {
+	if c.rdb == nil {
+		return nil
 	}
-	return nil
+	err := c.brk.do(func() error {
+		return c.rdb.Set(ctx, key, data, expire)
+	})
+	if isBreakerOpen(err) {
+		return nil
+	}
+	return err
+}
+
+// 写入本地缓存，跳过本地缓存时删除旧值
+func (c *store) setLocal(ctx context.Context, key string, data []byte, expire time.Duration) {
+	if !c.enableMdb {
+		return
+	}
+	if controlFrom(ctx).has(controlSkipLocal) {
+		c.mdb.Delete(key)
+		return
+	}
+	c.mdb.Set(key, data, expire)
+}
+
+// 增加随机浮动与过期保留时间后的最长缓存时间
+func (c *store) maxJitter(d time.Duration) time.Duration {
+	if c.jitterRatio > 0 {
+		d += time.Duration(float64(d) * c.jitterRatio)
+	}
+	return d + c.jitterRange + c.staleGrace
+}
+
+// 编码需要写入缓存的值，返回实际写入的缓存时间
+func (c *store) marshalCache(key string, val interface{}, expire time.Duration) ([]byte, time.Duration, error) {
+	marshal, err := encodeValue(c.codec, val)
+	if err != nil {
+		return nil, 0, err
+	}
+	marshal = c.compressValue(key, marshal)
+
+	var meta entryMeta
+	now := time.Now()
+	if c.softExpiration > 0 && c.softExpiration < expire {
+		meta.softExpireAt = now.Add(c.softExpiration)
+	}
+	expire = c.withJitter(expire)
+	if c.staleGrace > 0 {
+		// 过期后额外保留一段时间，加载失败时使用
+		meta.expireAt = now.Add(expire)
+		expire += c.staleGrace
+	}
+	if expire > 0 {
+		// 其他实例写入本地缓存时不超过该时间
+		meta.deadline = now.Add(expire)
+	}
+	return wrapEntry(marshal, meta), expire, nil
 }
 
-func (c *store) processCache(ctx context.Context, storeType int, key string, data []byte, v interface{}) error {
+// 缓存时间增加随机浮动
+func (c *store) withJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	if c.jitterRatio > 0 {
+		delta := int64(float64(d) * c.jitterRatio)
+		if delta > 0 {
+			d += time.Duration(rand.Int63n(2*delta+1) - delta)
+		}
+	}
+	if c.jitterRange > 0 {
+		d += time.Duration(rand.Int63n(int64(c.jitterRange)))
+	}
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	return d
+}
+
+func (c *store) processCache(ctx context.Context, storeType int, key string, data []byte, v interface{}) (bool, error) {
 	if bytes.Equal(data, notFoundPlaceholder) {
-		return errPlaceholder
+		c.stat.incr(&c.stat.placeholderHits)
+		return false, errPlaceholder
 	}
 	if bytes.Equal(data, []byte("")) {
-		return c.errNotFound
+		c.stat.incr(&c.stat.misses)
+		return false, c.errNotFound
+	}
+
+	value, meta := unwrapEntry(data)
+	now := time.Now()
+	if meta.expired(now) {
+		// 已过期，仅在加载失败时使用
+		c.stat.incr(&c.stat.misses)
+		return false, c.errNotFound
 	}
+	stale := meta.stale(now)
 
-	err := json.Unmarshal(data, v)
+	value, err := decompressValue(value)
+	ok := true
+	if err == nil {
+		ok, err = decodeValue(c.codec, value, v)
+	}
+	if !ok {
+		// 其他编解码器写入的缓存，当作未命中，重新加载后覆盖
+		logx.Infof("codec mismatch cache, key: %s", key)
+		c.stat.incr(&c.stat.misses)
+		return false, c.errNotFound
+	}
 	if err == nil {
 		if storeType == redisStore {
 			// local store not hit!
-			if c.enableMdb {
-				c.mdb.Set(key, data, c.expiration)
+			if c.enableMdb && !controlFrom(ctx).has(controlSkipLocal|controlNoWriteBack) {
+				if expire, ok := meta.localExpire(c.withJitter(c.expiration), now); ok {
+					c.mdb.Set(key, data, expire)
+				}
 			}
 
+			c.stat.incr(&c.stat.remoteHits)
 			logx.Infof("hit cache from redis [%s]", key)
 		} else {
+			c.stat.incr(&c.stat.localHits)
 			logx.Infof("hit cache from local [%s]", key)
 		}
-		return nil
+		return stale, nil
 	}
 
 	report := fmt.Sprintf("unmarshal cache, key: %s, value: %s, error: %v", key, data, err)
 	logx.Error(report)
 	//stat.Report(report)
+	c.stat.incr(&c.stat.corruptionDeletes)
+	c.stat.incr(&c.stat.misses)
 
-	if e := c.Del(ctx, key); e != nil {
+	if e := c.del(ctx, key); e != nil {
 		logx.Errorf("delete invalid cache, key: %s, value: %s, error: %v", key, data, e)
 	}
 
 	// returns errNotFound to reload the value by the given queryFn
-	return c.errNotFound
+	return false, c.errNotFound
 }
 
 func (c *store) setCacheWithNotFound(ctx context.Context, key string) error {
-	if c.enableMdb {
-		c.mdb.Set(key, notFoundPlaceholder, c.notFoundExpiration)
-	}
-
-	if c.rdb != nil {
-		return c.rdb.Set(ctx, key, notFoundPlaceholder, c.notFoundExpiration).Err()
-	}
-	return nil
+	expire := c.withJitter(c.notFoundExpiration)
+	c.unpinHotKeys(key)
+	c.setLocal(ctx, key, notFoundPlaceholder, expire)
+	return c.setRemote(ctx, key, notFoundPlaceholder, expire)
 }
@@ -0,0 +1,1077 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	bolt "go.etcd.io/bbolt"
+)
+
+var errTestNotFound = errors.New("not found")
+
+type testRow struct {
+	ID   int64
+	Name string
+}
+
+func TestTake(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	c := NewWithRemote(remote, errTestNotFound, DisableLocalCache())
+
+	var calls int
+	query := func(ctx context.Context, v interface{}) error {
+		calls++
+		*v.(*testRow) = testRow{ID: 1, Name: "foo"}
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		var row testRow
+		if err := c.Take(ctx, "row#1", &row, query); err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if row.Name != "foo" {
+			t.Errorf("Take() got %+v", row)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("query called %d times, want 1", calls)
+	}
+
+	stats := c.Stats()
+	if stats.Misses != 1 || stats.RemoteHits != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestTakeSharedCopies(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+
+	type taggedRow struct {
+		ID   int64
+		Tags []string
+	}
+	query := func(ctx context.Context, v interface{}) error {
+		time.Sleep(time.Millisecond * 50)
+		*v.(*taggedRow) = taggedRow{ID: 1, Tags: []string{"a", "b"}}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var row taggedRow
+			if err := c.Take(ctx, "row#1", &row, query); err != nil {
+				t.Errorf("Take() error = %v", err)
+				return
+			}
+			if row.ID != 1 || len(row.Tags) != 2 || row.Tags[0] != "a" {
+				t.Errorf("Take() got %+v", row)
+				return
+			}
+			// 每个调用者持有独立的值
+			row.ID = int64(i)
+			row.Tags[0] = strconv.Itoa(i)
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := c.Stats(); stats.SharedCalls == 0 {
+		t.Errorf("SharedCalls = 0, want > 0")
+	}
+}
+
+func TestTakeWithLock(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	instances := []Cache{
+		NewWithRemote(remote, errTestNotFound, EnableDistributedLock(time.Second, time.Second)),
+		NewWithRemote(remote, errTestNotFound, EnableDistributedLock(time.Second, time.Second)),
+	}
+
+	var calls int32
+	query := func(ctx context.Context, v interface{}) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 50)
+		*v.(*testRow) = testRow{ID: 1}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(c Cache) {
+			defer wg.Done()
+			var row testRow
+			if err := c.Take(ctx, "row#1", &row, query); err != nil || row.ID != 1 {
+				t.Errorf("Take() = %+v, %v", row, err)
+			}
+		}(instances[i%2])
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("query called %d times, want 1", n)
+	}
+	// 等待锁时的轮询不计入未命中
+	for _, c := range instances {
+		if stats := c.Stats(); stats.Misses > 1 {
+			t.Errorf("Misses = %d, want <= 1", stats.Misses)
+		}
+	}
+}
+
+func TestTakeWithLockHolderFailed(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	holder := NewWithRemote(remote, errTestNotFound, EnableDistributedLock(time.Second, time.Second))
+	waiter := NewWithRemote(remote, errTestNotFound, EnableDistributedLock(time.Second, time.Second))
+
+	errDB := errors.New("db down")
+	done := make(chan error)
+	go func() {
+		var row testRow
+		done <- holder.Take(ctx, "row#1", &row, func(ctx context.Context, v interface{}) error {
+			time.Sleep(time.Millisecond * 50)
+			return errDB
+		})
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	var row testRow
+	err := waiter.Take(ctx, "row#1", &row, func(ctx context.Context, v interface{}) error {
+		t.Error("waiter called query")
+		return nil
+	})
+	if err != ErrLoadFailed {
+		t.Errorf("Take() error = %v, want %v", err, ErrLoadFailed)
+	}
+	if err := <-done; err != errDB {
+		t.Errorf("Take() error = %v, want %v", err, errDB)
+	}
+}
+
+func TestTakeWithLockExpired(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	c := NewWithRemote(remote, errTestNotFound, EnableDistributedLock(time.Second, time.Second*5))
+
+	// 持锁实例崩溃，锁过期后重新抢锁，不等到超时
+	if _, err := remote.(RemoteLocker).SetNX(ctx, lockKeyPrefix+"row#1", []byte("crashed"), time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var row testRow
+	err := c.Take(ctx, "row#1", &row, func(ctx context.Context, v interface{}) error {
+		*v.(*testRow) = testRow{ID: 1}
+		return nil
+	})
+	if err != nil || row.ID != 1 {
+		t.Fatalf("Take() = %+v, %v", row, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Take() took %v, want lock expiry instead of wait timeout", elapsed)
+	}
+}
+
+// 记录发布的消息
+type publishRecorder struct {
+	redis.Cmdable
+	channel string
+	payload string
+}
+
+func (p *publishRecorder) Publish(ctx context.Context, channel string, message interface{}) *redis.IntCmd {
+	p.channel, p.payload = channel, message.(string)
+	return redis.NewIntCmd(ctx)
+}
+
+func TestSyncLocalCacheMessage(t *testing.T) {
+	ctx := context.Background()
+	// 同名的多个缓存都需要清除本地缓存
+	caches := []Cache{
+		NewWithRemote(nil, errTestNotFound, SetName("pubsub_task")),
+		NewWithRemote(nil, errTestNotFound, SetName("pubsub_task")),
+	}
+	for _, c := range caches {
+		if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pub := &publishRecorder{}
+	if err := PublishDeleteLocalCache(ctx, pub, "pubsub_task", "row#1"); err != nil {
+		t.Fatal(err)
+	}
+	if pub.channel != subChannel {
+		t.Errorf("published to %q, want %q", pub.channel, subChannel)
+	}
+
+	// 自己发出的消息不处理
+	handleMessage(pub.payload)
+	for _, c := range caches {
+		var row testRow
+		if err := c.Get(ctx, "row#1", &row); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	}
+
+	payload, err := json.MarshalToString(message{Hostname: "other#1", Table: "pubsub_task", Keys: []string{"row#1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handleMessage(payload)
+	for _, c := range caches {
+		var row testRow
+		if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+			t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+		}
+	}
+}
+
+func TestTakeStaleWhileRevalidate(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound, EnableStaleWhileRevalidate(time.Millisecond*20))
+
+	var calls int32
+	query := func(ctx context.Context, v interface{}) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 100)
+		*v.(*testRow) = testRow{ID: 1, Name: "new"}
+		return nil
+	}
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1, Name: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 30)
+
+	// 软过期后直接返回旧值，只有一个后台刷新
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		var row testRow
+		if err := c.Take(ctx, "row#1", &row, query); err != nil || row.Name != "old" {
+			t.Fatalf("Take() = %+v, %v", row, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*50 {
+		t.Errorf("Take() blocked for %v", elapsed)
+	}
+	time.Sleep(time.Millisecond * 150)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("query called %d times, want 1", n)
+	}
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != nil || row.Name != "new" {
+		t.Errorf("Get() = %+v, %v", row, err)
+	}
+
+	// 超过缓存时间后阻塞加载
+	if err := c.SetWithExpire(ctx, "row#2", testRow{ID: 2, Name: "old"}, time.Millisecond*40); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 60)
+	start = time.Now()
+	row = testRow{}
+	if err := c.Take(ctx, "row#2", &row, query); err != nil || row.Name != "new" {
+		t.Errorf("Take() = %+v, %v", row, err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+		t.Errorf("Take() returned after %v, want blocking on query", elapsed)
+	}
+}
+
+func TestTakeNotFound(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+
+	var calls int
+	query := func(ctx context.Context, v interface{}) error {
+		calls++
+		return errTestNotFound
+	}
+
+	for i := 0; i < 2; i++ {
+		var row testRow
+		if err := c.Take(ctx, "row#2", &row, query); err != errTestNotFound {
+			t.Fatalf("Take() error = %v, want %v", err, errTestNotFound)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("query called %d times, want 1", calls)
+	}
+	if stats := c.Stats(); stats.PlaceholderHits != 1 {
+		t.Errorf("PlaceholderHits = %d, want 1", stats.PlaceholderHits)
+	}
+}
+
+func TestCodecMismatch(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	gobCache := NewWithRemote(remote, errTestNotFound, DisableLocalCache(), SetCodec(GobCodec{}))
+	jsonCache := NewWithRemote(remote, errTestNotFound, DisableLocalCache())
+
+	if err := gobCache.Set(ctx, "row#3", testRow{ID: 3, Name: "gob"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var row testRow
+	if err := jsonCache.Get(ctx, "row#3", &row); err != errTestNotFound {
+		t.Fatalf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+	if err := gobCache.Get(ctx, "row#3", &row); err != nil || row.Name != "gob" {
+		t.Fatalf("Get() = %+v, %v", row, err)
+	}
+}
+
+func TestLocalExpireFollowsRemote(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	writer := NewWithRemote(remote, errTestNotFound)
+	reader := NewWithRemote(remote, errTestNotFound)
+
+	if err := writer.SetWithExpire(ctx, "row#1", testRow{ID: 1}, time.Millisecond*30); err != nil {
+		t.Fatal(err)
+	}
+	var row testRow
+	if err := reader.Get(ctx, "row#1", &row); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	// 本地缓存不超过二级缓存的剩余时间
+	if err := reader.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+}
+
+func TestTakeMany(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var missing []string
+	query := func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		missing = keys
+		return map[string]interface{}{
+			"row#2": &testRow{ID: 2},
+		}, nil
+	}
+
+	res := make(map[string]*testRow)
+	if err := c.TakeMany(ctx, []string{"row#1", "row#2", "row#3"}, &res, query); err != nil {
+		t.Fatalf("TakeMany() error = %v", err)
+	}
+	if len(res) != 2 || res["row#1"].ID != 1 || res["row#2"].ID != 2 {
+		t.Errorf("TakeMany() got %v", res)
+	}
+	if len(missing) != 2 {
+		t.Errorf("query keys = %v, want [row#2 row#3]", missing)
+	}
+
+	// row#3缓存为NotFound，不再调用query
+	var rows []testRow
+	if err := c.TakeMany(ctx, []string{"row#3", "row#2"}, &rows, func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		t.Errorf("unexpected query %v", keys)
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].ID != 2 {
+		t.Errorf("TakeMany() got %v", rows)
+	}
+}
+
+func TestInvalidateTags(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+
+	if err := c.SetWithTags(ctx, "row#1", testRow{ID: 1}, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWithTags(ctx, "row#2", testRow{ID: 2}, "tenant#2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTags(ctx, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+	if err := c.Get(ctx, "row#2", &row); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+}
+
+func TestTakeWithTagsHit(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+
+	query := func(ctx context.Context, v interface{}) error {
+		*v.(*testRow) = testRow{ID: 1}
+		return nil
+	}
+	var row testRow
+	if err := c.Take(ctx, "row#1", &row, query); err != nil {
+		t.Fatal(err)
+	}
+	// 已由Take写入的缓存，命中时同样加入标签集合
+	if err := c.TakeWithTags(ctx, "row#1", &row, query, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTags(ctx, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+}
+
+func TestTagsRewrite(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	c := NewWithRemote(remote, errTestNotFound, SetExpiration(time.Millisecond*200))
+
+	if err := c.SetWithTags(ctx, "row#1", testRow{ID: 1}, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 120)
+	// 重写缓存后标签集合不能早于key过期
+	if err := c.Set(ctx, "row#1", testRow{ID: 1, Name: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 120)
+
+	members, err := remote.(RemoteTagger).TagMembers(ctx, tagKey("tenant#1"))
+	if err != nil || len(members) != 1 || members[0] != "row#1" {
+		t.Errorf("TagMembers() = %v, %v", members, err)
+	}
+	if err := c.InvalidateTags(ctx, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+}
+
+func TestTakeWithTagsControl(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	c := NewWithRemote(remote, errTestNotFound)
+
+	query := func(ctx context.Context, v interface{}) error {
+		*v.(*testRow) = testRow{ID: 1}
+		return nil
+	}
+	for _, ctx := range []context.Context{WithBypass(ctx), WithNoWriteBack(ctx)} {
+		var row testRow
+		if err := c.TakeWithTags(ctx, "row#1", &row, query, "tenant#1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SetWithTags(WithBypass(ctx), "row#2", testRow{ID: 2}, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := remote.(RemoteTagger).TagMembers(ctx, tagKey("tenant#1"))
+	if err != nil || len(members) != 0 {
+		t.Errorf("TagMembers() = %v, %v", members, err)
+	}
+}
+
+func TestInvalidateTagsLocal(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(nil, errTestNotFound)
+
+	if err := c.SetWithTags(ctx, "row#1", testRow{ID: 1}, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTags(ctx, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+
+	// 不支持标签的二级缓存返回错误
+	plain := NewWithRemote(struct{ RemoteStore }{NewMemoryRemote()}, errTestNotFound)
+	if err := plain.InvalidateTags(ctx, "tenant#1"); err != ErrTagsUnsupported {
+		t.Errorf("InvalidateTags() error = %v, want %v", err, ErrTagsUnsupported)
+	}
+}
+
+func TestBoltTags(t *testing.T) {
+	ctx := context.Background()
+	remote, err := NewBoltRemote(t.TempDir() + "/cache.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	c := NewWithRemote(remote, errTestNotFound, DisableLocalCache())
+
+	if err := c.SetWithTags(ctx, "row#1", testRow{ID: 1}, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWithTags(ctx, "row#2", testRow{ID: 2}, "tenant#2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.InvalidateTags(ctx, "tenant#1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+	if err := c.Get(ctx, "row#2", &row); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if members, err := remote.TagMembers(ctx, tagKey("tenant#1")); err != nil || len(members) != 0 {
+		t.Errorf("TagMembers() = %v, %v", members, err)
+	}
+
+	// 成员过期后清理标签集合
+	if err := remote.TagAdd(ctx, []string{"tag#short"}, "row#3", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 5)
+	if err := remote.purge(); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltTagBucket).Bucket([]byte("tag#short")) != nil {
+			t.Error("expired tag set not purged")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var buf strings.Builder
+	if err := WritePrometheus(&buf, Stats{Name: "task", LocalHits: 3, LocalEntries: 2, BreakerOpen: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"# HELP cache_local_hits_total Number of hits in the local cache.\n" +
+			"# TYPE cache_local_hits_total counter\n" +
+			"cache_local_hits_total{name=\"task\"} 3\n",
+		"# TYPE cache_local_entries gauge\ncache_local_entries{name=\"task\"} 2\n",
+		"cache_breaker_open{name=\"task\"} 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WritePrometheus() missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLRUStore(t *testing.T) {
+	l := NewLRUStore(2, 0)
+	l.Set("a", []byte("1"), time.Minute)
+	l.Set("b", []byte("2"), time.Minute)
+	l.Get("a")
+	l.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := l.Get("b"); ok {
+		t.Error("least recently used key not evicted")
+	}
+	if _, ok := l.Get("a"); !ok {
+		t.Error("recently used key evicted")
+	}
+	if stats := l.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// 缓存时间为0时不过期
+	l.Set("d", []byte("4"), 0)
+	if _, ok := l.Get("d"); !ok {
+		t.Error("key without expiration not cached")
+	}
+}
+
+func TestZeroExpiration(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	writer := NewWithRemote(remote, errTestNotFound, SetExpiration(0))
+	reader := NewWithRemote(remote, errTestNotFound, SetExpiration(0))
+
+	if err := writer.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var row testRow
+	if err := reader.Get(ctx, "row#1", &row); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Cache{writer, reader} {
+		if stats := c.Stats(); stats.LocalEntries != 1 {
+			t.Errorf("LocalEntries = %d, want 1", stats.LocalEntries)
+		}
+	}
+}
+
+func TestTakeServeStale(t *testing.T) {
+	ctx := context.Background()
+	var staleKey string
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound,
+		EnableStaleOnError(time.Minute),
+		OnStale(func(ctx context.Context, key string, err error) {
+			staleKey = key
+		}))
+
+	if err := c.SetWithExpire(ctx, "row#1", testRow{ID: 1, Name: "old"}, time.Millisecond*10); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+
+	var row testRow
+	err := c.Take(ctx, "row#1", &row, func(ctx context.Context, v interface{}) error {
+		return errors.New("db down")
+	})
+	if err != nil || row.Name != "old" {
+		t.Fatalf("Take() = %+v, %v", row, err)
+	}
+	if staleKey != "row#1" {
+		t.Errorf("OnStale key = %q", staleKey)
+	}
+}
+
+func TestHotKeyDetection(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound,
+		DisableLocalCache(),
+		EnableHotKeyDetection(time.Minute, 3, time.Minute))
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		var row testRow
+		if err := c.Get(ctx, "row#1", &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hotKeys := c.HotKeys()
+	if len(hotKeys) != 1 || hotKeys[0].Key != "row#1" || hotKeys[0].Count != 5 {
+		t.Errorf("HotKeys() = %v", hotKeys)
+	}
+	if stats := c.Stats(); stats.LocalHits != 2 {
+		t.Errorf("LocalHits = %d, want 2", stats.LocalHits)
+	}
+}
+
+func TestHotKeyDetectorWindow(t *testing.T) {
+	d := newHotKeyDetector(time.Millisecond*100, 4, time.Minute, 2)
+
+	// 每2次访问采样一次，每次采样计为2次
+	var got []bool
+	for i := 0; i < 4; i++ {
+		got = append(got, d.record("row#1"))
+	}
+	if want := []bool{false, false, false, true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("record() = %v, want %v", got, want)
+	}
+	if hotKeys := d.hotKeys(); len(hotKeys) != 1 || hotKeys[0].Count != 4 {
+		t.Errorf("hotKeys() = %v", hotKeys)
+	}
+
+	// 窗口滑过后计数清零
+	time.Sleep(time.Millisecond * 120)
+	if hotKeys := d.hotKeys(); len(hotKeys) != 0 || len(d.totals) != 0 {
+		t.Errorf("hotKeys() = %v, totals = %v", hotKeys, d.totals)
+	}
+}
+
+func TestRefresher(t *testing.T) {
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound, SetExpiration(time.Millisecond*100))
+	r := NewRefresher(c, SetRefreshJitter(0))
+
+	var calls int32
+	r.Register("row#1", func(ctx context.Context) (interface{}, error) {
+		return testRow{ID: int64(atomic.AddInt32(&calls, 1))}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 300)
+
+	var row testRow
+	if err := c.Get(context.Background(), "row#1", &row); err != nil || row.ID < 2 {
+		t.Errorf("Get() = %+v, %v", row, err)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+	if n := atomic.LoadInt32(&calls); n < 3 {
+		t.Errorf("loader called %d times, want >= 3", n)
+	}
+}
+
+func TestRefresherExpirationJitter(t *testing.T) {
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound, SetExpiration(time.Millisecond*100), SetExpirationJitter(0.4))
+	r := NewRefresher(c)
+	r.Register("row#1", func(ctx context.Context) (interface{}, error) {
+		return testRow{ID: 1}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+	time.Sleep(time.Millisecond * 20)
+
+	// 随机缩短的缓存时间内完成刷新，key不会缺失
+	var misses int
+	for i := 0; i < 200; i++ {
+		var row testRow
+		if err := c.Get(context.Background(), "row#1", &row); err != nil {
+			misses++
+		}
+		time.Sleep(time.Millisecond * 2)
+	}
+	if misses > 0 {
+		t.Errorf("key missing on %d of 200 reads", misses)
+	}
+}
+
+func TestRefresherCancelPending(t *testing.T) {
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound)
+	r := NewRefresher(c, SetRefreshConcurrency(1))
+
+	// 第一个key占用并发，第二个key等待时取消
+	block := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	r.Register("row#1", block)
+	r.Register("row#2", block)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+	<-done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, entry := range r.entries {
+		if entry.running {
+			t.Errorf("entry %s still marked running", key)
+		}
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound, SetNamespace("task"), SetSchemaVersion(2))
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != nil || row.ID != 1 {
+		t.Fatalf("Get() = %+v, %v", row, err)
+	}
+
+	if v, err := c.BumpVersion(ctx); err != nil || v != 1 {
+		t.Fatalf("BumpVersion() = %d, %v", v, err)
+	}
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+}
+
+func TestContextControl(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	c := NewWithRemote(remote, errTestNotFound)
+
+	var calls int
+	query := func(ctx context.Context, v interface{}) error {
+		calls++
+		*v.(*testRow) = testRow{ID: 1, Name: "db"}
+		return nil
+	}
+
+	var row testRow
+	if err := c.Take(WithNoWriteBack(ctx), "row#1", &row, query); err != nil || row.Name != "db" {
+		t.Fatalf("Take() = %+v, %v", row, err)
+	}
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1, Name: "cache"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Take(WithBypass(ctx), "row#1", &row, query); err != nil || row.Name != "db" {
+		t.Fatalf("Take() = %+v, %v", row, err)
+	}
+	if calls != 2 {
+		t.Errorf("query called %d times, want 2", calls)
+	}
+
+	// 其他实例直接写入二级缓存，跳过本地缓存读取新值
+	data, _, _ := c.(*store).marshalCache("row#1", testRow{ID: 1, Name: "remote"}, time.Minute)
+	if err := remote.Set(ctx, "row#1", data, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(WithSkipLocal(ctx), "row#1", &row); err != nil || row.Name != "remote" {
+		t.Errorf("Get() = %+v, %v", row, err)
+	}
+
+	// 跳过本地缓存时二级缓存命中不回写本地缓存
+	other := NewWithRemote(remote, errTestNotFound)
+	if err := other.Get(WithSkipLocal(ctx), "row#1", &row); err != nil || row.Name != "remote" {
+		t.Errorf("Get() = %+v, %v", row, err)
+	}
+	if stats := other.Stats(); stats.LocalEntries != 0 {
+		t.Errorf("LocalEntries = %d, want 0", stats.LocalEntries)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemoryRemote()
+	name := strings.Repeat("location", 100)
+
+	for _, compression := range []Compression{SnappyCompression, ZstdCompression} {
+		c := NewWithRemote(remote, errTestNotFound, DisableLocalCache(), EnableCompression(compression, 64))
+		if err := c.Set(ctx, "row#1", testRow{ID: 1, Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := remote.Get(ctx, "row#1")
+		value, _ := unwrapEntry(data)
+		if err != nil || value[0] != compressMarker+byte(compression) || len(data) >= len(name) {
+			t.Errorf("compression %d: stored %d bytes, %v", compression, len(data), err)
+		}
+
+		// 未启用压缩的实例同样可以读取
+		plain := NewWithRemote(remote, errTestNotFound, DisableLocalCache())
+		var row testRow
+		if err := plain.Get(ctx, "row#1", &row); err != nil || row.Name != name {
+			t.Errorf("compression %d: Get() error = %v", compression, err)
+		}
+	}
+}
+
+type downRemote struct {
+	RemoteStore
+}
+
+func (downRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("redis down")
+}
+
+func (downRemote) Set(ctx context.Context, key string, val []byte, expire time.Duration) error {
+	return errors.New("redis down")
+}
+
+func TestBreakerOpen(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(downRemote{NewMemoryRemote()}, errTestNotFound, DisableLocalCache())
+
+	query := func(ctx context.Context, v interface{}) error {
+		*v.(*testRow) = testRow{ID: 1}
+		return nil
+	}
+	// 熔断后跳过redis直接调用query
+	var loaded int
+	for i := 0; i < 200; i++ {
+		var row testRow
+		if err := c.Take(ctx, "row#1", &row, query); err == nil && row.ID == 1 {
+			loaded++
+		}
+	}
+	stats := c.Stats()
+	if !stats.BreakerOpen || stats.BreakerRejects == 0 || loaded == 0 {
+		t.Errorf("Stats() = %+v, loaded %d times", stats, loaded)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	c := NewWithRemote(NewMemoryRemote(), errTestNotFound, SetNamespace("task"))
+
+	rows := map[string]testRow{
+		"row#1": {ID: 1, Name: "foo"},
+		"row#2": {ID: 2, Name: "new"},
+	}
+	for key, row := range rows {
+		if err := c.Set(ctx, key, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 更新数据库后删除缓存失败
+	rows["row#2"] = testRow{ID: 2, Name: "bar"}
+	if err := c.Set(ctx, "other#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	auditor, err := NewAuditor(c, &testRow{}, func(ctx context.Context, key string, v interface{}) error {
+		row, ok := rows[key]
+		if !ok {
+			return errTestNotFound
+		}
+		*v.(*testRow) = row
+		return nil
+	}, EnableAuditRepair())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := auditor.Audit(ctx, "row#*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 2 || report.Repaired != 1 || len(report.Mismatches) != 1 || report.Mismatches[0].Key != "row#2" {
+		t.Errorf("Audit() = %+v", report)
+	}
+
+	var row testRow
+	if err := c.Get(ctx, "row#2", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+}
+
+type flakyRemote struct {
+	RemoteStore
+	failures int
+}
+
+func (r *flakyRemote) Del(ctx context.Context, keys ...string) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("redis down")
+	}
+	return r.RemoteStore.Del(ctx, keys...)
+}
+
+func TestInvalidator(t *testing.T) {
+	ctx := context.Background()
+	remote := &flakyRemote{RemoteStore: NewMemoryRemote(), failures: 1}
+	c := NewWithRemote(remote, errTestNotFound, DisableBreaker())
+
+	var failed []string
+	inv := NewInvalidator(c,
+		SetInvalidateDelay(time.Millisecond*10),
+		SetInvalidateRetry(1, time.Millisecond),
+		OnInvalidateFailure(func(ctx context.Context, keys []string, err error) {
+			failed = keys
+		}))
+
+	if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.Invalidate(ctx, "row#1"); err != nil {
+		t.Fatalf("Invalidate() error = %v", err)
+	}
+
+	// 第一次删除后并发读取回写了旧值
+	if err := c.Set(ctx, "row#1", testRow{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	inv.Wait()
+
+	var row testRow
+	if err := c.Get(ctx, "row#1", &row); err != errTestNotFound {
+		t.Errorf("Get() error = %v, want %v", err, errTestNotFound)
+	}
+	if failed != nil {
+		t.Errorf("OnInvalidateFailure called with %v", failed)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		placeholder bool
+		codec       string
+		compression string
+		softExpire  bool
+		expire      bool
+	}{
+		{name: "placeholder", placeholder: true},
+		{name: "json", codec: "json"},
+		{name: "msgpack", opts: []Option{SetCodec(MsgpackCodec{})}, codec: "msgpack"},
+		{name: "gob", opts: []Option{SetCodec(GobCodec{})}, codec: "gob"},
+		{name: "compressed", opts: []Option{EnableCompression(SnappyCompression, 0)}, codec: "json", compression: "snappy"},
+		{
+			name:       "expiry metadata",
+			opts:       []Option{EnableStaleWhileRevalidate(time.Second), EnableStaleOnError(time.Minute)},
+			codec:      "json",
+			softExpire: true,
+			expire:     true,
+		},
+	}
+
+	row := testRow{ID: 1, Name: strings.Repeat("location", 10)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewWithRemote(nil, errTestNotFound, tt.opts...).(*store)
+			data := notFoundPlaceholder
+			if !tt.placeholder {
+				var err error
+				if data, _, err = c.marshalCache("row#1", row, time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			info, err := Inspect(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Placeholder != tt.placeholder || info.Size != len(data) || info.Codec != tt.codec || info.Compression != tt.compression {
+				t.Errorf("Inspect() = %+v", info)
+			}
+			if info.SoftExpireAt.IsZero() == tt.softExpire || info.ExpireAt.IsZero() == tt.expire {
+				t.Errorf("Inspect() expiry = %v, %v", info.SoftExpireAt, info.ExpireAt)
+			}
+
+			// gob编码不解码值
+			switch tt.codec {
+			case "json", "msgpack":
+				if v, ok := info.Value.(map[string]interface{}); !ok || v["Name"] != row.Name {
+					t.Errorf("Inspect() value = %#v", info.Value)
+				}
+			default:
+				if info.Value != nil {
+					t.Errorf("Inspect() value = %#v, want nil", info.Value)
+				}
+			}
+		})
+	}
+}
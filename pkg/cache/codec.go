@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 内置编解码器标识
+const (
+	jsonCodecID byte = iota + 1
+	gobCodecID
+	msgpackCodecID
+)
+
+type (
+	// Codec 缓存值编解码器
+	Codec interface {
+		// ID 编解码器标识，写在缓存值首字节，用于识别其他编码写入的缓存
+		// 取值需小于0x10，更高的值保留给缓存元数据
+		ID() byte
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	// JSONCodec 使用json-iterator编码，兼容标准库
+	JSONCodec struct{}
+
+	// GobCodec 使用encoding/gob编码，保留time.Time精度
+	GobCodec struct{}
+
+	// MsgpackCodec 使用msgpack编码，体积小速度快
+	MsgpackCodec struct{}
+)
+
+func (JSONCodec) ID() byte {
+	return jsonCodecID
+}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (GobCodec) ID() byte {
+	return gobCodecID
+}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (MsgpackCodec) ID() byte {
+	return msgpackCodecID
+}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// 编码缓存值，首字节为编解码器标识
+func encodeValue(codec Codec, v interface{}) ([]byte, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(data)+1)
+	buf = append(buf, codec.ID())
+	return append(buf, data...), nil
+}
+
+// 解码缓存值，ok为false表示缓存由其他编解码器写入
+func decodeValue(codec Codec, data []byte, v interface{}) (ok bool, err error) {
+	if len(data) == 0 || data[0] != codec.ID() {
+		return false, nil
+	}
+	return true, codec.Unmarshal(data[1:], v)
+}
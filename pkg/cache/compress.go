@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	// NoCompression 不压缩
+	NoCompression Compression = iota
+	// SnappyCompression 使用snappy压缩，速度快
+	SnappyCompression
+	// ZstdCompression 使用zstd压缩，压缩率高
+	ZstdCompression
+)
+
+// 压缩标记，[marker][压缩后的编码值]，marker = compressMarker + Compression
+const compressMarker byte = 0x20
+
+var errUnknownCompression = errors.New("cache: unknown compression")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// Compression 缓存值压缩算法
+type Compression byte
+
+// 启用压缩，编码后超过threshold字节的缓存值压缩后写入，
+// 压缩与未压缩的缓存可以同时存在
+func EnableCompression(compression Compression, threshold int) Option {
+	return func(s *store) {
+		s.compression = compression
+		s.compressThreshold = threshold
+	}
+}
+
+// 设置大key告警阈值，写入的缓存值超过limit字节时记录日志
+func SetBigKeyLimit(limit int) Option {
+	return func(s *store) {
+		s.bigKeyLimit = limit
+	}
+}
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+// 压缩编码后的缓存值，未超过阈值或压缩后没有变小时返回原值
+func (c *store) compressValue(key string, data []byte) []byte {
+	if c.compression != NoCompression && len(data) > c.compressThreshold {
+		var compressed []byte
+		switch c.compression {
+		case SnappyCompression:
+			compressed = snappy.Encode(nil, data)
+		case ZstdCompression:
+			if err := initZstd(); err != nil {
+				logx.Errorf("init zstd compression, error: %v", err)
+				break
+			}
+			compressed = zstdEncoder.EncodeAll(data, nil)
+		}
+		if compressed != nil && len(compressed)+1 < len(data) {
+			buf := make([]byte, 1, len(compressed)+1)
+			buf[0] = compressMarker + byte(c.compression)
+			data = append(buf, compressed...)
+		}
+	}
+
+	if c.bigKeyLimit > 0 && len(data) > c.bigKeyLimit {
+		logx.Errorf("big cache key, key: %s, size: %d, limit: %d", key, len(data), c.bigKeyLimit)
+	}
+	return data
+}
+
+// 解压缓存值，未压缩时返回原值
+func decompressValue(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] <= compressMarker || data[0] > compressMarker+byte(ZstdCompression) {
+		return data, nil
+	}
+
+	switch Compression(data[0] - compressMarker) {
+	case SnappyCompression:
+		return snappy.Decode(nil, data[1:])
+	case ZstdCompression:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(data[1:], nil)
+	default:
+		return nil, errUnknownCompression
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+)
+
+const (
+	// 不读写缓存，Take直接调用query
+	controlBypass control = 1 << iota
+	// 不读写本地缓存，用于写后立即读取
+	controlSkipLocal
+	// 不回写缓存，Take未命中时调用query但不写入缓存
+	controlNoWriteBack
+)
+
+type (
+	// 通过context控制单次调用的缓存行为
+	control uint8
+
+	controlKey struct{}
+)
+
+// WithBypass 跳过缓存，Take直接调用query，Get返回NotFound，Set不写入
+func WithBypass(ctx context.Context) context.Context {
+	return withControl(ctx, controlBypass)
+}
+
+// WithSkipLocal 跳过本地缓存，只读写二级缓存
+func WithSkipLocal(ctx context.Context) context.Context {
+	return withControl(ctx, controlSkipLocal)
+}
+
+// WithNoWriteBack 读取缓存，但未命中时加载的数据不回写缓存
+func WithNoWriteBack(ctx context.Context) context.Context {
+	return withControl(ctx, controlNoWriteBack)
+}
+
+func withControl(ctx context.Context, c control) context.Context {
+	return context.WithValue(ctx, controlKey{}, controlFrom(ctx)|c)
+}
+
+func controlFrom(ctx context.Context) control {
+	c, _ := ctx.Value(controlKey{}).(control)
+	return c
+}
+
+func (c control) has(flag control) bool {
+	return c&flag != 0
+}
+
+// 不同缓存行为的调用不共享singleflight结果
+func flightKey(ctx context.Context, key string) string {
+	if c := controlFrom(ctx); c != 0 {
+		return key + "#" + strconv.Itoa(int(c))
+	}
+	return key
+}
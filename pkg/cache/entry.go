@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// 缓存值元数据标记，编解码器标识需小于0x10
+const (
+	// [marker][8字节软过期时间戳][value]
+	softExpireMarker byte = 0x10
+	// [marker][8字节软过期时间戳][8字节过期时间戳][value]
+	expireMarker byte = 0x11
+	// [marker][8字节软过期时间戳][8字节过期时间戳][8字节删除时间戳][value]
+	deadlineMarker byte = 0x12
+)
+
+const (
+	softExpireHeaderLen = 9
+	expireHeaderLen     = 17
+	deadlineHeaderLen   = 25
+)
+
+// 缓存值元数据，零值表示未设置
+type entryMeta struct {
+	softExpireAt time.Time // 软过期时间，超过后后台刷新
+	expireAt     time.Time // 逻辑过期时间，超过后只在加载失败时使用
+	deadline     time.Time // 二级缓存中的删除时间，本地缓存不能超过该时间
+}
+
+func putTime(buf []byte, t time.Time) {
+	if !t.IsZero() {
+		binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+	}
+}
+
+func getTime(buf []byte) time.Time {
+	nano := int64(binary.BigEndian.Uint64(buf))
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// 写入元数据
+func wrapEntry(data []byte, meta entryMeta) []byte {
+	var buf []byte
+	switch {
+	case !meta.deadline.IsZero():
+		buf = make([]byte, deadlineHeaderLen, deadlineHeaderLen+len(data))
+		buf[0] = deadlineMarker
+		putTime(buf[1:], meta.softExpireAt)
+		putTime(buf[softExpireHeaderLen:], meta.expireAt)
+		putTime(buf[expireHeaderLen:], meta.deadline)
+	case !meta.expireAt.IsZero():
+		buf = make([]byte, expireHeaderLen, expireHeaderLen+len(data))
+		buf[0] = expireMarker
+		putTime(buf[1:], meta.softExpireAt)
+		putTime(buf[softExpireHeaderLen:], meta.expireAt)
+	case !meta.softExpireAt.IsZero():
+		buf = make([]byte, softExpireHeaderLen, softExpireHeaderLen+len(data))
+		buf[0] = softExpireMarker
+		putTime(buf[1:], meta.softExpireAt)
+	default:
+		return data
+	}
+	return append(buf, data...)
+}
+
+// 解析元数据，未写入元数据时返回零值
+func unwrapEntry(data []byte) ([]byte, entryMeta) {
+	var meta entryMeta
+	switch {
+	case len(data) >= deadlineHeaderLen && data[0] == deadlineMarker:
+		meta.softExpireAt = getTime(data[1:])
+		meta.expireAt = getTime(data[softExpireHeaderLen:])
+		meta.deadline = getTime(data[expireHeaderLen:])
+		return data[deadlineHeaderLen:], meta
+	case len(data) >= expireHeaderLen && data[0] == expireMarker:
+		meta.softExpireAt = getTime(data[1:])
+		meta.expireAt = getTime(data[softExpireHeaderLen:])
+		return data[expireHeaderLen:], meta
+	case len(data) >= softExpireHeaderLen && data[0] == softExpireMarker:
+		meta.softExpireAt = getTime(data[1:])
+		return data[softExpireHeaderLen:], meta
+	}
+	return data, meta
+}
+
+// 是否超过软过期时间
+func (m entryMeta) stale(now time.Time) bool {
+	return !m.softExpireAt.IsZero() && now.After(m.softExpireAt)
+}
+
+// 是否超过逻辑过期时间
+func (m entryMeta) expired(now time.Time) bool {
+	return !m.expireAt.IsZero() && now.After(m.expireAt)
+}
+
+// 本地缓存时间，d小于等于0表示不过期，不超过二级缓存中的剩余时间，
+// 二级缓存中已删除时ok为false
+func (m entryMeta) localExpire(d time.Duration, now time.Time) (expire time.Duration, ok bool) {
+	if m.deadline.IsZero() {
+		return d, true
+	}
+	remain := m.deadline.Sub(now)
+	if remain <= 0 {
+		return 0, false
+	}
+	if d <= 0 || remain < d {
+		return remain, true
+	}
+	return d, true
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	hotKeyBuckets       = 10
+	defaultHotKeyPinned = 1000
+)
+
+type (
+	// HotKey 热点key及其在统计窗口内的访问次数
+	HotKey struct {
+		Key   string `json:"key"`
+		Count uint64 `json:"count"`
+	}
+
+	// 滑动窗口统计key访问频率，超过阈值的key固定到本地缓存
+	hotKeyDetector struct {
+		threshold  uint64
+		sampleRate uint64        // 每sampleRate次访问采样一次
+		ttl        time.Duration // 固定到本地缓存的时间
+		width      time.Duration // 单个桶的时间跨度
+		seq        uint64
+		local      LocalStore
+
+		mu      sync.Mutex
+		buckets []map[string]uint64
+		totals  map[string]uint64 // 各桶计数之和
+		cur     int
+		start   time.Time // 当前桶开始时间
+	}
+)
+
+func newHotKeyDetector(window time.Duration, threshold uint64, ttl time.Duration, sampleRate uint64) *hotKeyDetector {
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	width := window / hotKeyBuckets
+	if width <= 0 {
+		width = time.Millisecond
+	}
+	d := &hotKeyDetector{
+		threshold:  threshold,
+		sampleRate: sampleRate,
+		ttl:        ttl,
+		width:      width,
+		local:      NewLRUStore(defaultHotKeyPinned, 0),
+		buckets:    make([]map[string]uint64, hotKeyBuckets),
+		totals:     make(map[string]uint64),
+		start:      time.Now(),
+	}
+	for i := range d.buckets {
+		d.buckets[i] = make(map[string]uint64)
+	}
+	return d
+}
+
+// 记录一次访问，返回key是否为热点，未采样的访问不加锁直接返回false
+func (d *hotKeyDetector) record(key string) bool {
+	if atomic.AddUint64(&d.seq, 1)%d.sampleRate != 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.advance(time.Now())
+	d.buckets[d.cur][key] += d.sampleRate
+	d.totals[key] += d.sampleRate
+	return d.totals[key] >= d.threshold
+}
+
+// 滑动窗口，清空过期的桶
+func (d *hotKeyDetector) advance(now time.Time) {
+	steps := int(now.Sub(d.start) / d.width)
+	if steps <= 0 {
+		return
+	}
+	for i := 0; i < steps && i < len(d.buckets); i++ {
+		d.cur = (d.cur + 1) % len(d.buckets)
+		for key, n := range d.buckets[d.cur] {
+			if d.totals[key] -= n; d.totals[key] == 0 {
+				delete(d.totals, key)
+			}
+		}
+		d.buckets[d.cur] = make(map[string]uint64)
+	}
+	d.start = d.start.Add(time.Duration(steps) * d.width)
+}
+
+// 当前热点key，按访问次数降序
+func (d *hotKeyDetector) hotKeys() []HotKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.advance(time.Now())
+	var res []HotKey
+	for key, n := range d.totals {
+		if n >= d.threshold {
+			res = append(res, HotKey{Key: key, Count: n})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count == res[j].Count {
+			return res[i].Key < res[j].Key
+		}
+		return res[i].Count > res[j].Count
+	})
+	return res
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// EntryInfo 缓存原始数据的解析结果，用于排查问题
+type EntryInfo struct {
+	Placeholder  bool        `json:"placeholder"`              // 是否为NotFound占位
+	Size         int         `json:"size"`                     // 原始数据大小
+	Codec        string      `json:"codec,omitempty"`          // 编解码器
+	Compression  string      `json:"compression,omitempty"`    // 压缩算法
+	SoftExpireAt time.Time   `json:"soft_expire_at,omitempty"` // 软过期时间
+	ExpireAt     time.Time   `json:"expire_at,omitempty"`      // 逻辑过期时间
+	Value        interface{} `json:"value,omitempty"`          // 解码后的值，gob编码无法解码时为nil
+}
+
+// Inspect 解析缓存原始数据，不需要知道缓存值的类型
+func Inspect(data []byte) (*EntryInfo, error) {
+	info := &EntryInfo{Size: len(data)}
+	if bytes.Equal(data, notFoundPlaceholder) {
+		info.Placeholder = true
+		return info, nil
+	}
+
+	value, meta := unwrapEntry(data)
+	info.SoftExpireAt = meta.softExpireAt
+	info.ExpireAt = meta.expireAt
+
+	if len(value) > 0 && value[0] > compressMarker && value[0] <= compressMarker+byte(ZstdCompression) {
+		info.Compression = Compression(value[0] - compressMarker).String()
+	}
+	value, err := decompressValue(value)
+	if err != nil {
+		return info, err
+	}
+	if len(value) == 0 {
+		return info, nil
+	}
+
+	switch value[0] {
+	case jsonCodecID:
+		info.Codec = "json"
+		err = json.Unmarshal(value[1:], &info.Value)
+	case msgpackCodecID:
+		info.Codec = "msgpack"
+		err = msgpack.Unmarshal(value[1:], &info.Value)
+	case gobCodecID:
+		info.Codec = "gob"
+	default:
+		info.Codec = "unknown"
+	}
+	return info, err
+}
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case SnappyCompression:
+		return "snappy"
+	case ZstdCompression:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	defaultInvalidateDelay   = time.Millisecond * 500
+	defaultInvalidateRetries = 3
+	defaultInvalidateBackoff = time.Millisecond * 100
+	maxInvalidateBackoff     = time.Second * 5
+)
+
+type (
+	// Invalidator 写后删除缓存，延迟后再次删除，避免并发读取回写旧值
+	Invalidator struct {
+		cache     Cache
+		delay     time.Duration // 第二次删除的延迟，0表示不执行
+		retries   int           // 删除失败后的重试次数
+		backoff   time.Duration // 首次重试间隔，之后每次翻倍
+		onFailure func(ctx context.Context, keys []string, err error)
+		wg        sync.WaitGroup
+	}
+
+	InvalidatorOption func(i *Invalidator)
+)
+
+// 设置第二次删除的延迟，一般略大于一次读库并回写缓存的耗时，0表示只删除一次
+func SetInvalidateDelay(d time.Duration) InvalidatorOption {
+	return func(i *Invalidator) {
+		i.delay = d
+	}
+}
+
+// 设置删除失败后的重试次数与首次重试间隔
+func SetInvalidateRetry(retries int, backoff time.Duration) InvalidatorOption {
+	return func(i *Invalidator) {
+		i.retries = retries
+		i.backoff = backoff
+	}
+}
+
+// 设置重试后仍然删除失败时的回调，用于告警或写入补偿队列
+func OnInvalidateFailure(fn func(ctx context.Context, keys []string, err error)) InvalidatorOption {
+	return func(i *Invalidator) {
+		i.onFailure = fn
+	}
+}
+
+// NewInvalidator 新建缓存删除器
+func NewInvalidator(c Cache, opts ...InvalidatorOption) *Invalidator {
+	i := &Invalidator{
+		cache:   c,
+		delay:   defaultInvalidateDelay,
+		retries: defaultInvalidateRetries,
+		backoff: defaultInvalidateBackoff,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Do 执行写操作，成功后删除缓存，写操作失败时不删除
+func (i *Invalidator) Do(ctx context.Context, fn func(ctx context.Context) error, keys ...string) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return i.Invalidate(ctx, keys...)
+}
+
+// Invalidate 删除缓存，并在延迟后再次删除，返回第一次删除的结果
+func (i *Invalidator) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := i.del(ctx, keys)
+	if err != nil {
+		i.fail(ctx, keys, err)
+	}
+
+	if i.delay > 0 {
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			time.Sleep(i.delay)
+			// 调用方ctx可能已结束，仍需删除
+			ctx := context.Background()
+			if err := i.del(ctx, keys); err != nil {
+				i.fail(ctx, keys, err)
+			}
+		}()
+	}
+	return err
+}
+
+// Wait 等待所有延迟删除完成，用于退出前
+func (i *Invalidator) Wait() {
+	i.wg.Wait()
+}
+
+// 删除缓存，失败后按指数退避重试
+func (i *Invalidator) del(ctx context.Context, keys []string) error {
+	backoff := i.backoff
+	err := i.cache.Del(ctx, keys...)
+	for n := 0; err != nil && n < i.retries; n++ {
+		logx.Errorf("invalidate cache, keys: %v, retry: %d, error: %v", keys, n+1, err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxInvalidateBackoff {
+			backoff = maxInvalidateBackoff
+		}
+		err = i.cache.Del(ctx, keys...)
+	}
+	return err
+}
+
+func (i *Invalidator) fail(ctx context.Context, keys []string, err error) {
+	logx.Errorf("invalidate cache failed, keys: %v, error: %v", keys, err)
+	if i.onFailure != nil {
+		i.onFailure(ctx, keys, err)
+	}
+}
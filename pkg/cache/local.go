@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLocalMaxEntries       = 10000
+	defaultLocalMaxBytes   int64 = 64 << 20
+
+	// 每个条目的固定内存开销估算
+	localEntryOverhead = 64
+)
+
+type (
+	// LocalStore 一级缓存
+	LocalStore interface {
+		Get(key string) ([]byte, bool)
+		// Set 写入缓存，expire小于等于0时不过期
+		Set(key string, val []byte, expire time.Duration)
+		Delete(key string)
+		Stats() LocalStats
+	}
+
+	// LocalStats 一级缓存统计
+	LocalStats struct {
+		Entries   int    // 当前条目数
+		Bytes     int64  // 当前内存占用估算
+		Evictions uint64 // 超出容量淘汰次数
+	}
+
+	// 按条目数与内存占用限制容量的LRU缓存
+	lruStore struct {
+		mu         sync.Mutex
+		maxEntries int
+		maxBytes   int64
+		bytes      int64
+		evictions  uint64
+		ll         *list.List
+		items      map[string]*list.Element
+	}
+
+	lruEntry struct {
+		key      string
+		val      []byte
+		expireAt time.Time // 零值表示不过期
+	}
+)
+
+// NewLRUStore 新建LRU一级缓存，maxEntries与maxBytes小于等于0时不限制
+func NewLRUStore(maxEntries int, maxBytes int64) LocalStore {
+	return &lruStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (e *lruEntry) size() int64 {
+	return int64(len(e.key)+len(e.val)) + localEntryOverhead
+}
+
+func (l *lruStore) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		l.removeElement(elem)
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	return entry.val, true
+}
+
+func (l *lruStore) Set(key string, val []byte, expire time.Duration) {
+	entry := &lruEntry{
+		key:      key,
+		val:      val,
+		expireAt: expireAt(expire),
+	}
+	// 单个值超过容量时不缓存
+	if l.maxBytes > 0 && entry.size() > l.maxBytes {
+		l.Delete(key)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.bytes -= elem.Value.(*lruEntry).size()
+		elem.Value = entry
+		l.bytes += entry.size()
+		l.ll.MoveToFront(elem)
+	} else {
+		l.items[key] = l.ll.PushFront(entry)
+		l.bytes += entry.size()
+	}
+
+	for l.overflow() {
+		l.removeElement(l.ll.Back())
+		l.evictions++
+	}
+}
+
+func (l *lruStore) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.items[key]; ok {
+		l.removeElement(elem)
+	}
+}
+
+func (l *lruStore) Stats() LocalStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return LocalStats{
+		Entries:   l.ll.Len(),
+		Bytes:     l.bytes,
+		Evictions: l.evictions,
+	}
+}
+
+func (l *lruStore) overflow() bool {
+	if l.ll.Len() == 0 {
+		return false
+	}
+	return (l.maxEntries > 0 && l.ll.Len() > l.maxEntries) ||
+		(l.maxBytes > 0 && l.bytes > l.maxBytes)
+}
+
+func (l *lruStore) removeElement(elem *list.Element) {
+	entry := l.ll.Remove(elem).(*lruEntry)
+	delete(l.items, entry.key)
+	l.bytes -= entry.size()
+}
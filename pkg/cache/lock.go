@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	// 分布式锁key前缀
+	lockKeyPrefix = "cached#lock#"
+	// 加载失败标记key后缀，值为失败的持锁实例的锁值
+	lockFailedSuffix = "#failed"
+
+	defaultLockExpiration   = time.Second * 3
+	defaultLockWaitTimeout  = time.Second
+	defaultLockPollInterval = time.Millisecond * 20
+	maxLockPollInterval     = time.Millisecond * 200
+)
+
+// ErrLoadFailed 持锁实例加载失败，等待的实例不再各自调用query
+var ErrLoadFailed = errors.New("cache: loader failed on the instance holding the lock")
+
+var lockSeq uint64
+
+func lockToken() []byte {
+	return []byte(fmt.Sprintf("%s#%d", hostname, atomic.AddUint64(&lockSeq, 1)))
+}
+
+// 跨实例加载，只有拿到锁的实例调用query，其他实例轮询等待缓存写入
+func (c *store) loadWithLock(ctx context.Context, locker RemoteLocker, key string, val interface{}, query func(context.Context, interface{}) error, expire time.Duration) error {
+	lockKey := lockKeyPrefix + key
+	failedKey := lockKey + lockFailedSuffix
+	token := lockToken()
+	deadline := time.Now().Add(c.lockWaitTimeout)
+	interval := defaultLockPollInterval
+	// 最近一次看到的持锁实例
+	var holder []byte
+
+	for {
+		ok, err := locker.SetNX(ctx, lockKey, token, c.lockExpiration)
+		if err != nil {
+			logx.Errorf("acquire cache lock, key: %s, error: %v", key, err)
+			return c.load(ctx, key, val, query, expire)
+		}
+		if ok {
+			defer unlock(locker, lockKey, token)
+			// 上一个持锁实例可能刚写入缓存
+			if err := c.peekRemote(ctx, key, val); err == nil || err == errPlaceholder {
+				return c.placeholderErr(err)
+			}
+			err := c.load(ctx, key, val, query, expire)
+			if err != nil && err != c.errNotFound && ctx.Err() == nil {
+				c.markLoadFailed(failedKey, token)
+			}
+			return err
+		}
+
+		// 锁被持有期间轮询缓存、锁与加载失败标记，锁释放后重新抢锁
+		for {
+			var values [][]byte
+			err := c.brk.do(func() (err error) {
+				values, err = c.rdb.MGet(ctx, key, lockKey, failedKey)
+				return err
+			})
+			if err == nil {
+				if values[0] != nil {
+					if err := c.peekEntry(values[0], val); err == nil || err == errPlaceholder {
+						return c.placeholderErr(err)
+					}
+				}
+				if holder != nil && bytes.Equal(values[2], holder) {
+					// 持锁实例加载失败，不再各自调用query
+					return ErrLoadFailed
+				}
+				if values[1] == nil {
+					// 锁已释放或持锁实例崩溃后锁已过期
+					break
+				}
+				holder = values[1]
+			}
+
+			if time.Now().After(deadline) {
+				// 等待超时，自行加载
+				logx.Infof("wait cache lock timeout, key: %s", key)
+				return c.load(ctx, key, val, query, expire)
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			if interval *= 2; interval > maxLockPollInterval {
+				interval = maxLockPollInterval
+			}
+		}
+	}
+}
+
+// 读取二级缓存，不计入统计与热点探测
+func (c *store) peekRemote(ctx context.Context, key string, val interface{}) error {
+	var data []byte
+	err := c.brk.do(func() (err error) {
+		data, err = c.rdb.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		return c.errNotFound
+	}
+	return c.peekEntry(data, val)
+}
+
+// 解码缓存原始数据，已过期或无法解码时返回errNotFound
+func (c *store) peekEntry(data []byte, val interface{}) error {
+	if bytes.Equal(data, notFoundPlaceholder) {
+		return errPlaceholder
+	}
+	if _, meta := unwrapEntry(data); meta.expired(time.Now()) {
+		return c.errNotFound
+	}
+	if ok, err := c.decodeRaw(data, val); !ok || err != nil {
+		return c.errNotFound
+	}
+	return nil
+}
+
+func (c *store) placeholderErr(err error) error {
+	if err == errPlaceholder {
+		return c.errNotFound
+	}
+	return err
+}
+
+// 记录加载失败，等待中的实例直接返回ErrLoadFailed
+func (c *store) markLoadFailed(failedKey string, token []byte) {
+	err := c.brk.do(func() error {
+		return c.rdb.Set(context.Background(), failedKey, token, c.lockWaitTimeout)
+	})
+	if err != nil {
+		logx.Errorf("mark cache load failed, key: %s, error: %v", failedKey, err)
+	}
+}
+
+func unlock(locker RemoteLocker, lockKey string, token []byte) {
+	// 调用方ctx可能已取消，仍需释放锁
+	if err := locker.DelIfEqual(context.Background(), lockKey, token); err != nil {
+		logx.Errorf("release cache lock, key: %s, error: %v", lockKey, err)
+	}
+}
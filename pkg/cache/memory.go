@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// 进程内二级缓存，用于单元测试或单机部署
+	memoryRemote struct {
+		mu    sync.Mutex
+		items map[string]memoryItem
+		tags  map[string]map[string]time.Time
+	}
+
+	memoryItem struct {
+		val      []byte
+		expireAt time.Time // 零值表示不过期
+	}
+
+	memoryPipeline struct {
+		ops []func(m *memoryRemote)
+	}
+)
+
+// NewMemoryRemote 新建进程内二级缓存
+func NewMemoryRemote() RemoteStore {
+	return &memoryRemote{
+		items: make(map[string]memoryItem),
+		tags:  make(map[string]map[string]time.Time),
+	}
+}
+
+func expireAt(expire time.Duration) time.Time {
+	if expire <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expire)
+}
+
+func (i memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && now.After(i.expireAt)
+}
+
+func (m *memoryRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if val, ok := m.get(key); ok {
+		return val, nil
+	}
+	return nil, ErrRemoteNil
+}
+
+func (m *memoryRemote) Set(ctx context.Context, key string, val []byte, expire time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, val, expire)
+	return nil
+}
+
+func (m *memoryRemote) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.del(keys...)
+	return nil
+}
+
+func (m *memoryRemote) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := make([][]byte, len(keys))
+	for i, key := range keys {
+		if val, ok := m.get(key); ok {
+			res[i] = val
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryRemote) Pipeline(ctx context.Context, fn func(pipe RemotePipeline)) error {
+	pipe := &memoryPipeline{}
+	fn(pipe)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range pipe.ops {
+		op(m)
+	}
+	return nil
+}
+
+func (m *memoryRemote) SetNX(ctx context.Context, key string, val []byte, expire time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(key); ok {
+		return false, nil
+	}
+	m.set(key, val, expire)
+	return true, nil
+}
+
+func (m *memoryRemote) DelIfEqual(ctx context.Context, key string, val []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cur, ok := m.get(key); ok && bytes.Equal(cur, val) {
+		m.del(key)
+	}
+	return nil
+}
+
+func (m *memoryRemote) Incr(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	if val, ok := m.get(key); ok {
+		var err error
+		if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	n++
+	m.set(key, []byte(strconv.FormatInt(n, 10)), 0)
+	return n, nil
+}
+
+func (m *memoryRemote) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.items))
+	now := time.Now()
+	for key, item := range m.items {
+		if !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Strings(keys)
+	return scanKeys(keys, cursor, match, count)
+}
+
+func (m *memoryRemote) TagAdd(ctx context.Context, tagKeys []string, member string, expire time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, tagKey := range tagKeys {
+		members, ok := m.tags[tagKey]
+		if !ok {
+			members = make(map[string]time.Time)
+			m.tags[tagKey] = members
+		}
+		for k, at := range members {
+			if now.After(at) {
+				delete(members, k)
+			}
+		}
+		members[member] = now.Add(expire)
+	}
+	return nil
+}
+
+func (m *memoryRemote) TagMembers(ctx context.Context, tagKey string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	res := make([]string, 0, len(m.tags[tagKey]))
+	for k, at := range m.tags[tagKey] {
+		if !now.After(at) {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// 按排序后的下标遍历key
+func scanKeys(keys []string, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if count <= 0 {
+		count = 10
+	}
+	var res []string
+	for i := cursor; i < uint64(len(keys)); i++ {
+		if i-cursor >= uint64(count) {
+			return res, i, nil
+		}
+		ok, err := path.Match(match, keys[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			res = append(res, keys[i])
+		}
+	}
+	return res, 0, nil
+}
+
+func (m *memoryRemote) get(key string) ([]byte, bool) {
+	item, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	if item.expired(time.Now()) {
+		delete(m.items, key)
+		return nil, false
+	}
+	return item.val, true
+}
+
+func (m *memoryRemote) set(key string, val []byte, expire time.Duration) {
+	// 复制一份，避免调用方修改
+	m.items[key] = memoryItem{
+		val:      append([]byte(nil), val...),
+		expireAt: expireAt(expire),
+	}
+}
+
+func (m *memoryRemote) del(keys ...string) {
+	for _, key := range keys {
+		delete(m.items, key)
+		delete(m.tags, key)
+	}
+}
+
+func (p *memoryPipeline) Set(key string, val []byte, expire time.Duration) {
+	p.ops = append(p.ops, func(m *memoryRemote) {
+		m.set(key, val, expire)
+	})
+}
+
+func (p *memoryPipeline) Del(keys ...string) {
+	p.ops = append(p.ops, func(m *memoryRemote) {
+		m.del(keys...)
+	})
+}
@@ -2,14 +2,21 @@ package cache
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"sync"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/tal-tech/go-zero/core/logx"
 )
 
 var (
+	syncMu     sync.RWMutex
 	enableSync bool
 	pubDb      *redis.Client
+
+	// 当前实例标识，用于忽略自己发出的消息
+	hostname = instanceName()
 )
 
 // redis订阅通讯，用于清除运行中内存缓存
@@ -21,38 +28,99 @@ type message struct {
 	Keys     []string
 }
 
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s#%d", host, os.Getpid())
+}
+
 func SyncLocalCache(ctx context.Context, rdb *redis.Client) {
+	syncMu.Lock()
 	enableSync = true
 	pubDb = rdb
+	syncMu.Unlock()
 
-	subscribe := pubDb.Subscribe(ctx, subChannel)
+	subscribe := rdb.Subscribe(ctx, subChannel)
 	channel := subscribe.Channel()
 
 	defer func() {
+		syncMu.Lock()
+		enableSync = false
+		pubDb = nil
+		syncMu.Unlock()
+
 		subscribe.Close()
-		pubDb.Close()
+		rdb.Close()
 	}()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case msg := <-channel:
+		case msg, ok := <-channel:
+			if !ok {
+				return
+			}
 			if msg.Channel == subChannel {
-				var req message
-				if err := json.UnmarshalFromString(msg.Payload, &req); err != nil {
-					logx.Errorf("%s", err.Error())
-					continue
-				}
-				if store, ok := stores[req.Table]; ok {
-					store.deleteLocalCache(req.Keys...)
-				}
+				handleMessage(msg.Payload)
 			}
 		}
 	}
 }
 
-func publishDeleteLocalCache() {
-	if pubDb != nil {
-		//pubDb.Publish()
+// 处理清除本地缓存的消息
+func handleMessage(payload string) {
+	var req message
+	if err := json.UnmarshalFromString(payload, &req); err != nil {
+		logx.Errorf("%s", err.Error())
+		return
+	}
+	// 自己发出的消息，本地缓存已清除
+	if req.Hostname == hostname {
+		return
+	}
+	// 未指定表名时清除所有缓存中的key
+	targets := allStores()
+	if req.Table != "" {
+		targets = lookupStores(req.Table)
+	}
+	for _, store := range targets {
+		store.deleteLocalCache(req.Keys...)
+	}
+}
+
+// PublishDeleteLocalCache 通知所有实例清除本地缓存，table为空时清除所有缓存中的key，
+// 用于在运行实例之外删除缓存
+func PublishDeleteLocalCache(ctx context.Context, rdb redis.Cmdable, table string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	payload, err := json.MarshalToString(message{
+		Hostname: hostname,
+		Table:    table,
+		Keys:     keys,
+	})
+	if err != nil {
+		return err
+	}
+	return rdb.Publish(ctx, subChannel, payload).Err()
+}
+
+// 通知其他实例清除本地缓存
+func publishDeleteLocalCache(ctx context.Context, table string, keys ...string) {
+	if table == "" || len(keys) == 0 {
+		return
+	}
+
+	syncMu.RLock()
+	enabled, rdb := enableSync, pubDb
+	syncMu.RUnlock()
+	if !enabled || rdb == nil {
+		return
+	}
+
+	if err := PublishDeleteLocalCache(ctx, rdb, table, keys...); err != nil {
+		logx.Errorf("publish delete local cache, table: %s, keys: %v, error: %v", table, keys, err)
 	}
 }
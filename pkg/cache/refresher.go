@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	defaultRefreshRatio       = 0.8
+	defaultRefreshJitter      = 0.05
+	defaultRefreshConcurrency = 4
+	refreshIdleInterval       = time.Second
+	refreshRetryInterval      = time.Millisecond * 100
+)
+
+type (
+	// Refresher 热点key后台刷新，在缓存过期前重新加载并写入缓存
+	Refresher struct {
+		cache       Cache
+		expiration  time.Duration // 写入缓存的过期时间
+		cacheJitter float64       // 缓存时间随机浮动比例，刷新时间按最短缓存时间计算
+		ratio       float64       // 在过期时间的该比例处刷新
+		jitter      float64       // 刷新时间随机浮动比例
+		concurrency int
+
+		mu      sync.Mutex
+		entries map[string]*refreshEntry
+		wake    chan struct{}
+	}
+
+	refreshEntry struct {
+		key     string
+		loader  func(ctx context.Context) (interface{}, error)
+		next    time.Time
+		running bool
+	}
+
+	RefresherOption func(r *Refresher)
+)
+
+// 设置刷新写入的缓存时间，默认与缓存一致
+func SetRefreshExpiration(d time.Duration) RefresherOption {
+	return func(r *Refresher) {
+		r.expiration = d
+	}
+}
+
+// 设置刷新时机，如0.8表示在缓存时间的80%处刷新
+func SetRefreshRatio(ratio float64) RefresherOption {
+	return func(r *Refresher) {
+		r.ratio = ratio
+	}
+}
+
+// 设置刷新时间随机浮动比例
+func SetRefreshJitter(jitter float64) RefresherOption {
+	return func(r *Refresher) {
+		r.jitter = jitter
+	}
+}
+
+// 设置同时刷新的最大数量
+func SetRefreshConcurrency(n int) RefresherOption {
+	return func(r *Refresher) {
+		r.concurrency = n
+	}
+}
+
+// NewRefresher 新建后台刷新器，调用Run后开始刷新
+func NewRefresher(c Cache, opts ...RefresherOption) *Refresher {
+	r := &Refresher{
+		cache:       c,
+		expiration:  defaultExpiration,
+		ratio:       defaultRefreshRatio,
+		jitter:      defaultRefreshJitter,
+		concurrency: defaultRefreshConcurrency,
+		entries:     make(map[string]*refreshEntry),
+		wake:        make(chan struct{}, 1),
+	}
+	if s, ok := c.(*store); ok {
+		r.expiration = s.expiration
+		r.cacheJitter = s.jitterRatio
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.concurrency <= 0 {
+		r.concurrency = 1
+	}
+	return r
+}
+
+// Register 注册热点key，立即加载一次，之后在过期前刷新
+func (r *Refresher) Register(key string, loader func(ctx context.Context) (interface{}, error)) {
+	r.mu.Lock()
+	r.entries[key] = &refreshEntry{
+		key:    key,
+		loader: loader,
+		next:   time.Now(),
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Unregister 取消注册，缓存按正常过期
+func (r *Refresher) Unregister(key string) {
+	r.mu.Lock()
+	delete(r.entries, key)
+	r.mu.Unlock()
+}
+
+// Run 执行刷新直到ctx结束，返回前等待正在执行的刷新完成
+func (r *Refresher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, r.concurrency)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-timer.C:
+		}
+
+		due := r.due(time.Now())
+		for i, entry := range due {
+			select {
+			case <-ctx.Done():
+				// 未执行的key恢复为待刷新，下次Run时刷新
+				r.release(due[i:])
+				return
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(entry *refreshEntry) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				r.refresh(ctx, entry)
+			}(entry)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(r.nextDelay(time.Now()))
+	}
+}
+
+// 返回到期的key并标记为执行中
+func (r *Refresher) due(now time.Time) []*refreshEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res []*refreshEntry
+	for _, entry := range r.entries {
+		if !entry.running && !now.Before(entry.next) {
+			entry.running = true
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+// 取消执行中标记
+func (r *Refresher) release(entries []*refreshEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.running = false
+	}
+}
+
+// 距离下一次刷新的时间
+func (r *Refresher) nextDelay(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delay := refreshIdleInterval
+	for _, entry := range r.entries {
+		if entry.running {
+			continue
+		}
+		if d := entry.next.Sub(now); d < delay {
+			delay = d
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+func (r *Refresher) refresh(ctx context.Context, entry *refreshEntry) {
+	interval := r.interval()
+
+	val, err := entry.loader(ctx)
+	if err == nil {
+		err = r.cache.SetWithExpire(ctx, entry.key, val, r.expiration)
+	}
+	if err != nil {
+		logx.Errorf("refresh hot key, key: %s, error: %v", entry.key, err)
+		// 失败后在缓存过期前重试
+		if interval = (r.minExpiration() - interval) / 2; interval < refreshRetryInterval {
+			interval = refreshRetryInterval
+		}
+	}
+
+	r.mu.Lock()
+	entry.running = false
+	entry.next = time.Now().Add(interval)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// 缓存随机浮动后的最短缓存时间
+func (r *Refresher) minExpiration() time.Duration {
+	if r.cacheJitter <= 0 {
+		return r.expiration
+	}
+	return time.Duration(float64(r.expiration) * (1 - r.cacheJitter))
+}
+
+// 刷新间隔
+func (r *Refresher) interval() time.Duration {
+	d := float64(r.minExpiration()) * r.ratio
+	if r.jitter > 0 {
+		d += d * r.jitter * (2*rand.Float64() - 1)
+	}
+	if d < float64(time.Millisecond) {
+		d = float64(time.Millisecond)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrRemoteNil 二级缓存中不存在该key
+var ErrRemoteNil = errors.New("cache: remote key not found")
+
+type (
+	// RemoteStore 二级缓存
+	RemoteStore interface {
+		// Get 获取缓存，不存在时返回ErrRemoteNil
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, val []byte, expire time.Duration) error
+		Del(ctx context.Context, keys ...string) error
+		// MGet 批量获取缓存，不存在的key对应nil
+		MGet(ctx context.Context, keys ...string) ([][]byte, error)
+		// Pipeline 批量执行写操作
+		Pipeline(ctx context.Context, fn func(pipe RemotePipeline)) error
+	}
+
+	// RemotePipeline 批量写操作
+	RemotePipeline interface {
+		Set(key string, val []byte, expire time.Duration)
+		Del(keys ...string)
+	}
+
+	// RemoteLocker 支持分布式锁的二级缓存，用于EnableDistributedLock
+	RemoteLocker interface {
+		// SetNX key不存在时写入
+		SetNX(ctx context.Context, key string, val []byte, expire time.Duration) (bool, error)
+		// DelIfEqual 值相等时删除
+		DelIfEqual(ctx context.Context, key string, val []byte) error
+	}
+
+	// RemoteTagger 支持标签集合的二级缓存，用于SetWithTags等方法
+	RemoteTagger interface {
+		// TagAdd 将member加入标签集合，member与集合在expire后过期
+		TagAdd(ctx context.Context, tagKeys []string, member string, expire time.Duration) error
+		// TagMembers 返回标签集合中未过期的成员
+		TagMembers(ctx context.Context, tagKey string) ([]string, error)
+	}
+
+	// RemoteScanner 支持遍历key的二级缓存，用于Auditor
+	RemoteScanner interface {
+		// Scan 按glob模式遍历key，cursor为0开始，返回的next为0时遍历结束
+		Scan(ctx context.Context, cursor uint64, match string, count int64) (keys []string, next uint64, err error)
+	}
+
+	redisRemote struct {
+		client redis.Cmdable
+	}
+
+	redisPipeline struct {
+		ctx  context.Context
+		pipe redis.Pipeliner
+	}
+)
+
+// 记录标签成员，同时清理已过期成员并延长集合过期时间
+var tagScript = redis.NewScript(`
+for i = 1, #KEYS do
+	redis.call('ZADD', KEYS[i], ARGV[1], ARGV[2])
+	redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', ARGV[3])
+	if redis.call('PTTL', KEYS[i]) < tonumber(ARGV[4]) then
+		redis.call('PEXPIRE', KEYS[i], ARGV[4])
+	end
+end
+return 1
+`)
+
+// 只释放自己持有的锁
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// NewRedisRemote 使用go-redis作为二级缓存
+func NewRedisRemote(client redis.Cmdable) RemoteStore {
+	return &redisRemote{client: client}
+}
+
+func (r *redisRemote) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrRemoteNil
+	}
+	return data, err
+}
+
+func (r *redisRemote) Set(ctx context.Context, key string, val []byte, expire time.Duration) error {
+	return r.client.Set(ctx, key, val, expire).Err()
+}
+
+func (r *redisRemote) Del(ctx context.Context, keys ...string) error {
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *redisRemote) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]byte, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			res[i] = []byte(s)
+		}
+	}
+	return res, nil
+}
+
+func (r *redisRemote) Pipeline(ctx context.Context, fn func(pipe RemotePipeline)) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fn(&redisPipeline{ctx: ctx, pipe: pipe})
+		return nil
+	})
+	return err
+}
+
+func (r *redisRemote) SetNX(ctx context.Context, key string, val []byte, expire time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, val, expire).Result()
+}
+
+func (r *redisRemote) DelIfEqual(ctx context.Context, key string, val []byte) error {
+	return unlockScript.Run(ctx, r.client, []string{key}, val).Err()
+}
+
+func (r *redisRemote) Incr(ctx context.Context, key string) (int64, error) {
+	return r.client.Incr(ctx, key).Result()
+}
+
+func (r *redisRemote) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	return r.client.Scan(ctx, cursor, match, count).Result()
+}
+
+func (r *redisRemote) TagAdd(ctx context.Context, tagKeys []string, member string, expire time.Duration) error {
+	now := time.Now()
+	return tagScript.Run(ctx, r.client, tagKeys,
+		now.Add(expire).UnixNano()/int64(time.Millisecond),
+		member,
+		now.UnixNano()/int64(time.Millisecond),
+		expire.Milliseconds(),
+	).Err()
+}
+
+func (r *redisRemote) TagMembers(ctx context.Context, tagKey string) ([]string, error) {
+	return r.client.ZRangeByScore(ctx, tagKey, &redis.ZRangeBy{
+		Min: "(" + formatMillis(time.Now()),
+		Max: "+inf",
+	}).Result()
+}
+
+func (p *redisPipeline) Set(key string, val []byte, expire time.Duration) {
+	p.pipe.Set(p.ctx, key, val, expire)
+}
+
+func (p *redisPipeline) Del(keys ...string) {
+	p.pipe.Del(p.ctx, keys...)
+}
+
+func formatMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+// 加载失败时使用已过期但仍在保留期内的缓存，成功时返回true
+func (c *store) serveStale(ctx context.Context, key string, val interface{}, cause error) bool {
+	if c.staleGrace <= 0 {
+		return false
+	}
+
+	data, ok := c.getRaw(ctx, key)
+	if !ok || bytes.Equal(data, notFoundPlaceholder) {
+		return false
+	}
+	value, _ := unwrapEntry(data)
+	value, err := decompressValue(value)
+	if err != nil {
+		return false
+	}
+
+	// 解码到临时变量，避免失败时改写val
+	dst := reflect.ValueOf(val)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return false
+	}
+	tmp := reflect.New(dst.Type().Elem())
+	if ok, err := decodeValue(c.codec, value, tmp.Interface()); !ok || err != nil {
+		return false
+	}
+	dst.Elem().Set(tmp.Elem())
+
+	logx.Errorf("serve stale cache, key: %s, error: %v", key, cause)
+	if c.onStale != nil {
+		c.onStale(ctx, key, cause)
+	}
+	return true
+}
+
+// 读取缓存原始数据，不检查过期时间
+func (c *store) getRaw(ctx context.Context, key string) ([]byte, bool) {
+	if c.enableMdb {
+		if data, ok := c.mdb.Get(key); ok {
+			return data, true
+		}
+	}
+	if c.rdb != nil {
+		var data []byte
+		err := c.brk.do(func() (err error) {
+			data, err = c.rdb.Get(ctx, key)
+			return err
+		})
+		if err == nil {
+			return data, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+type (
+	// Stats 缓存统计快照
+	Stats struct {
+		Name              string `json:"name"`
+		LocalHits         uint64 `json:"local_hits"`         // 本地缓存命中
+		RemoteHits        uint64 `json:"remote_hits"`        // redis命中
+		Misses            uint64 `json:"misses"`             // 未命中
+		PlaceholderHits   uint64 `json:"placeholder_hits"`   // NotFound占位命中
+		LoaderErrors      uint64 `json:"loader_errors"`      // query加载失败
+		CorruptionDeletes uint64 `json:"corruption_deletes"` // 解码失败删除
+		SharedCalls       uint64 `json:"shared_calls"`       // singleflight共享结果
+		LocalEntries      int    `json:"local_entries"`      // 一级缓存条目数
+		LocalBytes        int64  `json:"local_bytes"`        // 一级缓存内存占用
+		LocalEvictions    uint64 `json:"local_evictions"`    // 一级缓存淘汰次数
+		BreakerOpen       bool   `json:"breaker_open"`       // 二级缓存是否熔断
+		BreakerRejects    uint64 `json:"breaker_rejects"`    // 熔断跳过的二级缓存操作
+	}
+
+	// 缓存计数器，并发安全
+	stat struct {
+		localHits         uint64
+		remoteHits        uint64
+		misses            uint64
+		placeholderHits   uint64
+		loaderErrors      uint64
+		corruptionDeletes uint64
+		sharedCalls       uint64
+	}
+)
+
+func (s *stat) incr(counter *uint64) {
+	atomic.AddUint64(counter, 1)
+}
+
+func (s *stat) snapshot(name string) Stats {
+	return Stats{
+		Name:              name,
+		LocalHits:         atomic.LoadUint64(&s.localHits),
+		RemoteHits:        atomic.LoadUint64(&s.remoteHits),
+		Misses:            atomic.LoadUint64(&s.misses),
+		PlaceholderHits:   atomic.LoadUint64(&s.placeholderHits),
+		LoaderErrors:      atomic.LoadUint64(&s.loaderErrors),
+		CorruptionDeletes: atomic.LoadUint64(&s.corruptionDeletes),
+		SharedCalls:       atomic.LoadUint64(&s.sharedCalls),
+	}
+}
+
+func (s *Stats) add(o Stats) {
+	s.LocalHits += o.LocalHits
+	s.RemoteHits += o.RemoteHits
+	s.Misses += o.Misses
+	s.PlaceholderHits += o.PlaceholderHits
+	s.LoaderErrors += o.LoaderErrors
+	s.CorruptionDeletes += o.CorruptionDeletes
+	s.SharedCalls += o.SharedCalls
+	s.LocalEntries += o.LocalEntries
+	s.LocalBytes += o.LocalBytes
+	s.LocalEvictions += o.LocalEvictions
+	s.BreakerOpen = s.BreakerOpen || o.BreakerOpen
+	s.BreakerRejects += o.BreakerRejects
+}
+
+// Requests 总请求数
+func (s Stats) Requests() uint64 {
+	return s.LocalHits + s.RemoteHits + s.PlaceholderHits + s.Misses
+}
+
+// HitRatio 命中率，NotFound占位同样算作命中
+func (s Stats) HitRatio() float64 {
+	total := s.Requests()
+	if total == 0 {
+		return 0
+	}
+	return float64(s.LocalHits+s.RemoteHits+s.PlaceholderHits) / float64(total)
+}
+
+// AllStats 返回所有已命名缓存的统计，同名缓存合并统计
+func AllStats() []Stats {
+	storesMu.RLock()
+	res := make([]Stats, 0, len(stores))
+	for name, named := range stores {
+		stats := Stats{Name: name}
+		for _, s := range named {
+			stats.add(s.Stats())
+		}
+		res = append(res, stats)
+	}
+	storesMu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// 导出的指标
+var metrics = []struct {
+	name  string
+	typ   string
+	help  string
+	value func(s Stats) uint64
+}{
+	{"cache_local_hits_total", "counter", "Number of hits in the local cache.", func(s Stats) uint64 { return s.LocalHits }},
+	{"cache_remote_hits_total", "counter", "Number of hits in redis.", func(s Stats) uint64 { return s.RemoteHits }},
+	{"cache_misses_total", "counter", "Number of cache misses.", func(s Stats) uint64 { return s.Misses }},
+	{"cache_placeholder_hits_total", "counter", "Number of not found placeholder hits.", func(s Stats) uint64 { return s.PlaceholderHits }},
+	{"cache_loader_errors_total", "counter", "Number of failed query calls.", func(s Stats) uint64 { return s.LoaderErrors }},
+	{"cache_corruption_deletes_total", "counter", "Number of entries deleted because they could not be decoded.", func(s Stats) uint64 { return s.CorruptionDeletes }},
+	{"cache_shared_calls_total", "counter", "Number of calls sharing a singleflight result.", func(s Stats) uint64 { return s.SharedCalls }},
+	{"cache_local_entries", "gauge", "Number of entries in the local cache.", func(s Stats) uint64 { return uint64(s.LocalEntries) }},
+	{"cache_local_bytes", "gauge", "Estimated memory used by the local cache.", func(s Stats) uint64 { return uint64(s.LocalBytes) }},
+	{"cache_local_evictions_total", "counter", "Number of entries evicted from the local cache.", func(s Stats) uint64 { return s.LocalEvictions }},
+	{"cache_breaker_open", "gauge", "Whether the remote store circuit breaker is open.", func(s Stats) uint64 {
+		if s.BreakerOpen {
+			return 1
+		}
+		return 0
+	}},
+	{"cache_breaker_rejects_total", "counter", "Number of remote store calls rejected by the circuit breaker.", func(s Stats) uint64 { return s.BreakerRejects }},
+}
+
+// WritePrometheus 以Prometheus文本格式输出统计，未指定stats时输出所有已命名缓存
+func WritePrometheus(w io.Writer, stats ...Stats) error {
+	if len(stats) == 0 {
+		stats = AllStats()
+	}
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.typ)
+		for _, s := range stats {
+			fmt.Fprintf(buf, "%s{name=%s} %d\n", m.name, strconv.Quote(s.Name), m.value(s))
+		}
+	}
+	return buf.Flush()
+}
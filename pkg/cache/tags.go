@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 标签集合key前缀，集合成员为缓存key
+	tagKeyPrefix = "cached#tag#"
+	// 缓存key所属标签的集合key前缀，集合成员为标签集合key，重写缓存时据此延长标签集合
+	keyTagsPrefix = "cached#tags-of#"
+)
+
+// ErrTagsUnsupported 二级缓存不支持标签集合
+var ErrTagsUnsupported = errors.New("cache: remote store does not support tags")
+
+// 单次删除的key数量
+const invalidateBatchSize = 500
+
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+func (c *store) SetWithTags(ctx context.Context, key string, val interface{}, tags ...string) error {
+	if _, err := c.tagStore(); err != nil {
+		return err
+	}
+	prefix := c.keyPrefix(ctx)
+	if err := c.set(ctx, prefix+key, val, c.expiration); err != nil {
+		return err
+	}
+	if controlFrom(ctx).has(controlBypass) {
+		// 未写入缓存
+		return nil
+	}
+	return c.addTags(ctx, prefix, prefix+key, c.expiration, tags...)
+}
+
+func (c *store) TakeWithTags(ctx context.Context, key string, val interface{}, query func(context.Context, interface{}) error, tags ...string) error {
+	if _, err := c.tagStore(); err != nil {
+		return err
+	}
+	prefix := c.keyPrefix(ctx)
+	var loaded bool
+	err := c.take(ctx, prefix+key, val, func(ctx context.Context, v interface{}) error {
+		if err := query(ctx, v); err != nil {
+			return err
+		}
+		loaded = true
+		return nil
+	}, c.expiration)
+	if err != nil {
+		return err
+	}
+	// 跳过缓存或不回写时加载的数据未写入缓存
+	ctl := controlFrom(ctx)
+	if ctl.has(controlBypass) || loaded && ctl.has(controlNoWriteBack) {
+		return nil
+	}
+	return c.addTags(ctx, prefix, prefix+key, c.expiration, tags...)
+}
+
+func (c *store) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	ts, err := c.tagStore()
+	if err != nil {
+		return err
+	}
+	tagger := ts.(RemoteTagger)
+
+	prefix := c.keyPrefix(ctx)
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		key := prefix + tagKey(tag)
+		tagKeys = append(tagKeys, key)
+
+		var members []string
+		err := c.brk.do(func() (err error) {
+			members, err = tagger.TagMembers(ctx, key)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for len(members) > 0 {
+			n := invalidateBatchSize
+			if n > len(members) {
+				n = len(members)
+			}
+			if err := c.del(ctx, members[:n]...); err != nil {
+				return err
+			}
+			keyTags := make([]string, 0, n)
+			for _, member := range members[:n] {
+				keyTags = append(keyTags, keyTagsPrefix+member)
+			}
+			if err := c.brk.do(func() error {
+				return ts.Del(ctx, keyTags...)
+			}); err != nil {
+				return err
+			}
+			members = members[n:]
+		}
+	}
+	return c.brk.do(func() error {
+		return ts.Del(ctx, tagKeys...)
+	})
+}
+
+// 将key加入标签集合，标签集合与key使用相同前缀
+func (c *store) addTags(ctx context.Context, prefix, key string, expire time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	ts, err := c.tagStore()
+	if err != nil {
+		return err
+	}
+	tagger := ts.(RemoteTagger)
+
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, prefix+tagKey(tag))
+	}
+	atomic.StoreInt32(&c.tagged, 1)
+	// 按最长的随机缓存时间计算，保证标签集合晚于成员过期
+	err = c.brk.do(func() error {
+		return addKeyTags(ctx, tagger, keys, key, c.maxJitter(expire))
+	})
+	if isBreakerOpen(err) {
+		return nil
+	}
+	return err
+}
+
+// 重写缓存时延长key所在的标签集合，expire为实际写入的缓存时间
+func (c *store) retag(ctx context.Context, key string, expire time.Duration) error {
+	if atomic.LoadInt32(&c.tagged) == 0 {
+		return nil
+	}
+	ts, err := c.tagStore()
+	if err != nil {
+		return nil
+	}
+	tagger := ts.(RemoteTagger)
+
+	err = c.brk.do(func() error {
+		keys, err := tagger.TagMembers(ctx, keyTagsPrefix+key)
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		return addKeyTags(ctx, tagger, keys, key, expire)
+	})
+	if isBreakerOpen(err) {
+		return nil
+	}
+	return err
+}
+
+// 将key加入标签集合，并记录key所属的标签集合
+func addKeyTags(ctx context.Context, tagger RemoteTagger, tagKeys []string, key string, expire time.Duration) error {
+	if err := tagger.TagAdd(ctx, tagKeys, key, expire); err != nil {
+		return err
+	}
+	for _, tagKey := range tagKeys {
+		if err := tagger.TagAdd(ctx, []string{keyTagsPrefix + key}, tagKey, expire); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 记录标签集合的存储，未使用二级缓存时记录在进程内
+func (c *store) tagStore() (RemoteStore, error) {
+	if c.rdb == nil {
+		return c.localTags, nil
+	}
+	if _, ok := c.rdb.(RemoteTagger); !ok {
+		return nil, ErrTagsUnsupported
+	}
+	return c.rdb, nil
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tal-tech/go-zero/core/logx"
+)
+
+const (
+	// 命名空间版本号key前缀
+	versionKeyPrefix = "cached#version#"
+	// 读取版本号使用的singleflight key前缀
+	versionFlightPrefix = "version#"
+	// 本地版本号的刷新间隔，其他实例更新版本号后最多延迟该时间生效
+	versionRefreshInterval = time.Second
+)
+
+// ErrNoNamespace 未设置命名空间时不能更新版本号
+var ErrNoNamespace = errors.New("cache: namespace not set")
+
+type (
+	// RemoteCounter 支持计数器的二级缓存，用于跨实例共享命名空间版本号
+	RemoteCounter interface {
+		// Incr 计数加1并返回新值，key不存在时从0开始计数
+		Incr(ctx context.Context, key string) (int64, error)
+	}
+
+	// 本地缓存的命名空间版本号
+	versionState struct {
+		mu       sync.RWMutex
+		version  int64
+		loadedAt time.Time
+	}
+)
+
+// 设置key命名空间，所有key增加命名空间与版本号前缀，一般使用表名
+func SetNamespace(ns string) Option {
+	return func(s *store) {
+		s.namespace = ns
+	}
+}
+
+// 设置缓存值的结构版本，结构变化后修改版本号，旧结构的缓存不再读取
+func SetSchemaVersion(v int) Option {
+	return func(s *store) {
+		s.schemaVersion = v
+	}
+}
+
+func versionKey(ns string) string {
+	return versionKeyPrefix + ns
+}
+
+// BumpNamespaceVersion 更新命名空间版本号，该命名空间下的所有缓存失效，返回新版本号
+func BumpNamespaceVersion(ctx context.Context, remote RemoteStore, ns string) (int64, error) {
+	if ns == "" {
+		return 0, ErrNoNamespace
+	}
+	counter, ok := remote.(RemoteCounter)
+	if !ok {
+		return 0, fmt.Errorf("cache: remote store %T does not support version bumping", remote)
+	}
+	return counter.Incr(ctx, versionKey(ns))
+}
+
+func (c *store) BumpVersion(ctx context.Context) (int64, error) {
+	if c.namespace == "" {
+		return 0, ErrNoNamespace
+	}
+	if _, ok := c.rdb.(RemoteCounter); !ok {
+		// 只使用本地缓存，版本号仅在当前进程内生效
+		c.ver.mu.Lock()
+		defer c.ver.mu.Unlock()
+		c.ver.version++
+		return c.ver.version, nil
+	}
+
+	var version int64
+	err := c.brk.do(func() (err error) {
+		version, err = BumpNamespaceVersion(ctx, c.rdb, c.namespace)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	c.ver.set(version)
+	return version, nil
+}
+
+// 缓存key前缀，未设置命名空间与结构版本时为空
+func (c *store) keyPrefix(ctx context.Context) string {
+	if c.namespace == "" {
+		if c.schemaVersion == 0 {
+			return ""
+		}
+		return fmt.Sprintf("v%d#", c.schemaVersion)
+	}
+	return fmt.Sprintf("%s#v%d.%d#", c.namespace, c.schemaVersion, c.version(ctx))
+}
+
+// 增加前缀后的缓存key
+func (c *store) fullKeys(ctx context.Context, keys []string) []string {
+	prefix := c.keyPrefix(ctx)
+	if prefix == "" {
+		return keys
+	}
+	res := make([]string, len(keys))
+	for i, key := range keys {
+		res[i] = prefix + key
+	}
+	return res
+}
+
+// 当前命名空间版本号，定期从二级缓存刷新，读取失败时沿用旧值
+func (c *store) version(ctx context.Context) int64 {
+	if _, ok := c.rdb.(RemoteCounter); !ok {
+		c.ver.mu.RLock()
+		defer c.ver.mu.RUnlock()
+		return c.ver.version
+	}
+
+	version, fresh := c.ver.get(time.Now())
+	if fresh {
+		return version
+	}
+
+	res, _, _ := c.g.Do(versionFlightPrefix+c.namespace, func() (interface{}, error) {
+		var data []byte
+		err := c.brk.do(func() (err error) {
+			data, err = c.rdb.Get(ctx, versionKey(c.namespace))
+			return err
+		})
+		switch err {
+		case nil:
+			v, err := strconv.ParseInt(string(data), 10, 64)
+			if err != nil {
+				logx.Errorf("parse cache version, namespace: %s, value: %s, error: %v", c.namespace, data, err)
+				break
+			}
+			version = v
+		case ErrRemoteNil:
+			version = 0
+		default:
+			logx.Errorf("load cache version, namespace: %s, error: %v", c.namespace, err)
+		}
+		c.ver.set(version)
+		return version, nil
+	})
+	return res.(int64)
+}
+
+// 返回版本号及是否在刷新间隔内
+func (v *versionState) get(now time.Time) (int64, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.version, !v.loadedAt.IsZero() && now.Sub(v.loadedAt) < versionRefreshInterval
+}
+
+func (v *versionState) set(version int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.version = version
+	v.loadedAt = time.Now()
+}
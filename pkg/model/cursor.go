@@ -0,0 +1,272 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	cursorNext = "n"
+	cursorPrev = "p"
+
+	// 游标中时间的格式，MySQL可直接比较
+	cursorTimeLayout = "2006-01-02 15:04:05.999999999"
+)
+
+var (
+	// ErrInvalidCursor 游标无法解析或与排序字段不匹配
+	ErrInvalidCursor = errors.New("model: invalid cursor")
+
+	errCursorEntity = errors.New("model: cursor pagination entity must be a pointer to slice of struct")
+)
+
+type (
+	// OrderBy 游标分页的排序字段，字段值不能为NULL
+	OrderBy struct {
+		Column string
+		Desc   bool
+	}
+
+	// CursorPaginator 游标分页结果，游标为空表示没有上一页或下一页
+	CursorPaginator struct {
+		PerPage     uint   `json:"per_page"`      // 每页的数据条数
+		NextCursor  string `json:"next_cursor"`   // 下一页游标
+		PrevCursor  string `json:"prev_cursor"`   // 上一页游标
+		HasNextPage bool   `json:"has_next_page"` // 是否有下一页
+		HasPrevPage bool   `json:"has_prev_page"` // 是否有上一页
+	}
+
+	// 游标内容，values为边界行排序字段的值
+	cursor struct {
+		Direction string        `json:"d"`
+		Values    []interface{} `json:"v"`
+	}
+)
+
+// 升序
+func Asc(column string) OrderBy {
+	return OrderBy{Column: column}
+}
+
+// 降序
+func Desc(column string) OrderBy {
+	return OrderBy{Column: column, Desc: true}
+}
+
+// CursorPaginate 游标分页，按orders排序，排序字段最后自动追加主键保证顺序唯一，
+// cursor为空时返回第一页，不查询总数
+func (c *core) CursorPaginate(ctx context.Context, cursorStr string, perPage uint, entity interface{}, conditions map[string]interface{}, orders ...OrderBy) (paginator *CursorPaginator, err error) {
+	dest := reflect.ValueOf(entity)
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Slice {
+		return nil, errCursorEntity
+	}
+	if perPage == 0 {
+		perPage = c.perPage
+	}
+	orders = c.cursorOrders(orders)
+
+	cur := cursor{Direction: cursorNext}
+	if cursorStr != "" {
+		if cur, err = decodeCursor(cursorStr); err != nil {
+			return nil, err
+		}
+		if len(cur.Values) != len(orders) {
+			return nil, ErrInvalidCursor
+		}
+	}
+	backward := cur.Direction == cursorPrev
+
+	where := make(map[string]interface{}, len(conditions)+3)
+	for k, v := range conditions {
+		where[k] = v
+	}
+	if cur.Values != nil {
+		if err := keysetWhere(where, orders, cur.Values, backward); err != nil {
+			return nil, err
+		}
+	}
+	where["_orderby"] = orderByClause(orders, backward)
+	// 多查一条用于判断是否还有数据
+	where["_limit"] = []uint{perPage + 1}
+
+	if err = c.Find(ctx, entity, where); err != nil {
+		return nil, err
+	}
+
+	rows := dest.Elem()
+	more := uint(rows.Len()) > perPage
+	if more {
+		rows.Set(rows.Slice(0, int(perPage)))
+	}
+	if backward {
+		reverseSlice(rows)
+	}
+
+	paginator = &CursorPaginator{PerPage: perPage}
+	if backward {
+		paginator.HasPrevPage = more
+		paginator.HasNextPage = true
+	} else {
+		paginator.HasPrevPage = cursorStr != ""
+		paginator.HasNextPage = more
+	}
+	if rows.Len() == 0 {
+		// 没有数据时无法生成游标
+		paginator.HasNextPage, paginator.HasPrevPage = false, false
+		return paginator, nil
+	}
+
+	if paginator.HasNextPage {
+		if paginator.NextCursor, err = encodeCursor(cursorNext, rows.Index(rows.Len()-1), orders); err != nil {
+			return nil, err
+		}
+	}
+	if paginator.HasPrevPage {
+		if paginator.PrevCursor, err = encodeCursor(cursorPrev, rows.Index(0), orders); err != nil {
+			return nil, err
+		}
+	}
+	return paginator, nil
+}
+
+// 追加主键作为最后的排序字段，方向与最后一个字段一致
+func (c *core) cursorOrders(orders []OrderBy) []OrderBy {
+	res := make([]OrderBy, 0, len(orders)+1)
+	var desc bool
+	for _, order := range orders {
+		if order.Column == c.primaryKey {
+			return append(res, order)
+		}
+		res = append(res, order)
+		desc = order.Desc
+	}
+	return append(res, OrderBy{Column: c.primaryKey, Desc: desc})
+}
+
+// 构建 (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ... 条件，
+// 已有的_or条件合并到每个分支中
+func keysetWhere(where map[string]interface{}, orders []OrderBy, values []interface{}, backward bool) error {
+	callerOr, hasOr := where["_or"]
+	branches := make([]map[string]interface{}, 0, len(orders))
+	for i, order := range orders {
+		branch := make(map[string]interface{}, i+2)
+		for j := 0; j < i; j++ {
+			branch[orders[j].Column] = values[j]
+		}
+		if values[i] == nil {
+			return ErrInvalidCursor
+		}
+		op := ">"
+		if order.Desc != backward {
+			op = "<"
+		}
+		branch[order.Column+" "+op] = values[i]
+		if hasOr {
+			branch["_or"] = callerOr
+		}
+		branches = append(branches, branch)
+	}
+	where["_or"] = branches
+	return nil
+}
+
+func orderByClause(orders []OrderBy, backward bool) string {
+	parts := make([]string, 0, len(orders))
+	for _, order := range orders {
+		dir := "ASC"
+		if order.Desc != backward {
+			dir = "DESC"
+		}
+		parts = append(parts, fmt.Sprintf("`%s` %s", order.Column, dir))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func reverseSlice(v reflect.Value) {
+	swap := reflect.Swapper(v.Interface())
+	for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// 读取行中排序字段的值并编码为游标
+func encodeCursor(direction string, row reflect.Value, orders []OrderBy) (string, error) {
+	values := make([]interface{}, 0, len(orders))
+	for _, order := range orders {
+		v, err := columnValue(row, order.Column)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, v)
+	}
+	data, err := json.Marshal(cursor{Direction: direction, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var cur cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, ErrInvalidCursor
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&cur); err != nil {
+		return cur, ErrInvalidCursor
+	}
+	if cur.Direction != cursorNext && cur.Direction != cursorPrev {
+		return cur, ErrInvalidCursor
+	}
+	for i, v := range cur.Values {
+		if n, ok := v.(json.Number); ok {
+			if i64, err := n.Int64(); err == nil {
+				cur.Values[i] = i64
+			} else if f64, err := n.Float64(); err == nil {
+				cur.Values[i] = f64
+			}
+		}
+	}
+	return cur, nil
+}
+
+// 按db标签读取字段值
+func columnValue(row reflect.Value, column string) (interface{}, error) {
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		if row.IsNil() {
+			return nil, errCursorEntity
+		}
+		row = row.Elem()
+	}
+	if row.Kind() != reflect.Struct {
+		return nil, errCursorEntity
+	}
+
+	t := row.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get(ScannerTag), ",")[0]; tag != column {
+			continue
+		}
+		v := row.Field(i)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil, fmt.Errorf("model: cursor column %s is null", column)
+			}
+			v = v.Elem()
+		}
+		if tm, ok := v.Interface().(time.Time); ok {
+			return tm.Format(cursorTimeLayout), nil
+		}
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("model: cursor column %s not found in %s", column, t)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/didi/gendry/builder"
+)
+
+type cursorRow struct {
+	IntId     int64     `db:"int_id"`
+	Status    int8      `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	orders := []OrderBy{Desc("created_at"), Asc("status"), Asc("int_id")}
+	row := cursorRow{IntId: 7, Status: 1, CreatedAt: time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC)}
+
+	s, err := encodeCursor(cursorNext, reflect.ValueOf(row), orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := decodeCursor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"2021-03-01 08:00:00", int64(1), int64(7)}
+	if cur.Direction != cursorNext || !reflect.DeepEqual(cur.Values, want) {
+		t.Fatalf("decodeCursor() = %+v", cur)
+	}
+
+	// created_at相同时按status、int_id区分
+	at := "2021-03-01 08:00:00"
+	tests := []struct {
+		backward bool
+		cond     string
+	}{
+		{
+			cond: "SELECT * FROM task WHERE (((created_at<?) OR (created_at=? AND status>?) OR (created_at=? AND status=? AND int_id>?)) AND user_id=?) " +
+				"ORDER BY `created_at` DESC, `status` ASC, `int_id` ASC",
+		},
+		{
+			backward: true,
+			cond: "SELECT * FROM task WHERE (((created_at>?) OR (created_at=? AND status<?) OR (created_at=? AND status=? AND int_id<?)) AND user_id=?) " +
+				"ORDER BY `created_at` ASC, `status` DESC, `int_id` DESC",
+		},
+	}
+	for _, tt := range tests {
+		where := map[string]interface{}{"user_id": "u1"}
+		if err := keysetWhere(where, orders, cur.Values, tt.backward); err != nil {
+			t.Fatal(err)
+		}
+		where["_orderby"] = orderByClause(orders, tt.backward)
+		cond, vals, err := builder.BuildSelect("task", where, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantVals := []interface{}{at, at, int64(1), at, int64(1), int64(7), "u1"}
+		if cond != tt.cond || !reflect.DeepEqual(vals, wantVals) {
+			t.Errorf("BuildSelect(backward=%v) = %s, %v", tt.backward, cond, vals)
+		}
+	}
+
+	if _, err := decodeCursor("not a cursor"); err != ErrInvalidCursor {
+		t.Errorf("decodeCursor() error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestCursorOrders(t *testing.T) {
+	c := &core{primaryKey: "int_id"}
+	got := c.cursorOrders([]OrderBy{Desc("created_at")})
+	want := []OrderBy{Desc("created_at"), Desc("int_id")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cursorOrders() = %v, want %v", got, want)
+	}
+}
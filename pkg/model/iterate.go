@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/didi/gendry/builder"
+	"github.com/didi/gendry/scanner"
+)
+
+var (
+	errEachEntity  = errors.New("model: each entity must be a pointer to struct")
+	errChunkEntity = errors.New("model: chunk entity must be a pointer to slice")
+	errChunkSize   = errors.New("model: chunk size must be greater than 0")
+)
+
+// 只返回当前行的scanner.Rows，用于逐行扫描
+type singleRow struct {
+	rows    scanner.Rows
+	columns []string
+	done    bool
+}
+
+func (r *singleRow) Close() error {
+	return nil
+}
+
+func (r *singleRow) Columns() ([]string, error) {
+	return r.columns, nil
+}
+
+func (r *singleRow) Next() bool {
+	if r.done {
+		return false
+	}
+	r.done = true
+	return true
+}
+
+func (r *singleRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(dest...)
+}
+
+// Each 逐行读取查询结果，entity为结构体指针，只用于确定行类型，
+// 每行扫描到新的结构体指针后调用fn，fn返回错误时停止
+func (c *core) Each(ctx context.Context, entity interface{}, conditions map[string]interface{}, fn func(row interface{}) error) error {
+	t := reflect.TypeOf(entity)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return errEachEntity
+	}
+
+	cond, vals, err := builder.BuildSelect(c.table, c.scope(conditions), nil)
+	if err != nil {
+		return err
+	}
+	rows, err := c.db.QueryContext(ctx, cond, vals...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		row := reflect.New(t.Elem()).Interface()
+		if err := scanner.Scan(&singleRow{rows: rows, columns: columns}, row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Chunk 按主键顺序分批读取，每批size条，entity为切片指针，只用于确定批次类型，
+// 每批扫描到新的切片指针后调用fn，下一批从上一批最后的主键开始查询，fn返回错误时停止
+func (c *core) Chunk(ctx context.Context, entity interface{}, conditions map[string]interface{}, size uint, fn func(batch interface{}) error) error {
+	t := reflect.TypeOf(entity)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return errChunkEntity
+	}
+	if size == 0 {
+		return errChunkSize
+	}
+
+	where := make(map[string]interface{}, len(conditions)+3)
+	for k, v := range conditions {
+		where[k] = v
+	}
+	where["_orderby"] = fmt.Sprintf("`%s` ASC", c.primaryKey)
+	where["_limit"] = []uint{size}
+
+	for {
+		batch := reflect.New(t.Elem())
+		if err := c.Find(ctx, batch.Interface(), where); err != nil {
+			return err
+		}
+		rows := batch.Elem()
+		if rows.Len() == 0 {
+			return nil
+		}
+		if err := fn(batch.Interface()); err != nil {
+			return err
+		}
+		if uint(rows.Len()) < size {
+			return nil
+		}
+
+		last, err := columnValue(rows.Index(rows.Len()-1), c.primaryKey)
+		if err != nil {
+			return err
+		}
+		where[c.primaryKey+" >"] = last
+	}
+}
@@ -0,0 +1,288 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/didi/gendry/scanner"
+)
+
+type fakeRows struct {
+	columns []string
+	data    [][]interface{}
+	cur     int
+}
+
+func (r *fakeRows) Close() error               { return nil }
+func (r *fakeRows) Columns() ([]string, error) { return r.columns, nil }
+
+func (r *fakeRows) Next() bool {
+	r.cur++
+	return r.cur <= len(r.data)
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	for i, v := range r.data[r.cur-1] {
+		*dest[i].(*interface{}) = v
+	}
+	return nil
+}
+
+func TestSingleRowScan(t *testing.T) {
+	rows := &fakeRows{
+		columns: []string{"int_id", "status"},
+		data:    [][]interface{}{{int64(1), int64(0)}, {int64(2), int64(1)}},
+	}
+
+	var got []cursorRow
+	for rows.Next() {
+		var row cursorRow
+		if err := scanner.Scan(&singleRow{rows: rows, columns: rows.columns}, &row); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	if len(got) != 2 || got[0].IntId != 1 || got[1].IntId != 2 || got[1].Status != 1 {
+		t.Errorf("scan rows = %+v", got)
+	}
+}
+
+// 按顺序返回结果集的sql驱动，记录执行的查询
+type fakeDriver struct {
+	columns []string
+	results [][][]driver.Value
+	queries []string
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+type fakeDriverRows struct {
+	columns []string
+	data    [][]driver.Value
+}
+
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	d := c.d
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.queries = append(d.queries, fmt.Sprint(query, values))
+	if len(d.results) == 0 {
+		return &fakeDriverRows{columns: d.columns}, nil
+	}
+	rows := &fakeDriverRows{columns: d.columns, data: d.results[0]}
+	d.results = d.results[1:]
+	return rows, nil
+}
+
+func (r *fakeDriverRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeDriverRows) Close() error {
+	return nil
+}
+
+func (r *fakeDriverRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.data[0])
+	r.data = r.data[1:]
+	return nil
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name    string
+		results [][][]driver.Value
+		queries []string
+		batches [][]int64
+	}{
+		{
+			name:    "short batch",
+			results: [][][]driver.Value{{{int64(1)}, {int64(2)}}, {{int64(3)}}},
+			queries: []string{
+				"SELECT * FROM task WHERE (status=?) ORDER BY `int_id` ASC LIMIT ?,?[1 0 2]",
+				"SELECT * FROM task WHERE (status=? AND int_id>?) ORDER BY `int_id` ASC LIMIT ?,?[1 2 0 2]",
+			},
+			batches: [][]int64{{1, 2}, {3}},
+		},
+		{
+			name:    "empty batch",
+			results: [][][]driver.Value{{{int64(1)}, {int64(2)}}, {{int64(3)}, {int64(4)}}, {}},
+			queries: []string{
+				"SELECT * FROM task WHERE (status=?) ORDER BY `int_id` ASC LIMIT ?,?[1 0 2]",
+				"SELECT * FROM task WHERE (status=? AND int_id>?) ORDER BY `int_id` ASC LIMIT ?,?[1 2 0 2]",
+				"SELECT * FROM task WHERE (status=? AND int_id>?) ORDER BY `int_id` ASC LIMIT ?,?[1 4 0 2]",
+			},
+			batches: [][]int64{{1, 2}, {3, 4}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{columns: []string{"int_id"}, results: tt.results}
+			c := &core{db: sql.OpenDB(d), table: "task", primaryKey: "int_id"}
+
+			var batches [][]int64
+			err := c.Chunk(context.Background(), &[]cursorRow{}, map[string]interface{}{"status": 1}, 2, func(batch interface{}) error {
+				var ids []int64
+				for _, row := range *batch.(*[]cursorRow) {
+					ids = append(ids, row.IntId)
+				}
+				batches = append(batches, ids)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(d.queries, tt.queries) {
+				t.Errorf("Chunk() queries = %q", d.queries)
+			}
+			if !reflect.DeepEqual(batches, tt.batches) {
+				t.Errorf("Chunk() batches = %v", batches)
+			}
+		})
+	}
+}
+
+func TestEach(t *testing.T) {
+	d := &fakeDriver{
+		columns: []string{"int_id", "status"},
+		results: [][][]driver.Value{{{int64(1), int64(0)}, {int64(2), int64(1)}, {int64(3), int64(1)}}},
+	}
+	c := &core{db: sql.OpenDB(d), table: "task", softDelete: "deleted_at"}
+
+	errStop := errors.New("stop")
+	var ids []int64
+	err := c.Each(context.Background(), &cursorRow{}, map[string]interface{}{"status": 1}, func(row interface{}) error {
+		ids = append(ids, row.(*cursorRow).IntId)
+		if len(ids) == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("Each() error = %v, want %v", err, errStop)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Errorf("Each() rows = %v", ids)
+	}
+	if want := []string{"SELECT * FROM task WHERE (status=? AND deleted_at IS NULL)[1]"}; !reflect.DeepEqual(d.queries, want) {
+		t.Errorf("Each() queries = %q", d.queries)
+	}
+}
+
+func TestCursorPaginate(t *testing.T) {
+	orders := []OrderBy{Asc("status"), Asc("int_id")}
+	cursorOf := func(direction string, row cursorRow) string {
+		s, err := encodeCursor(direction, reflect.ValueOf(row), orders)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		results [][][]driver.Value
+		query   string
+		ids     []int64
+		next    string
+		prev    string
+	}{
+		{
+			name:    "first page",
+			results: [][][]driver.Value{{{int64(1), int64(0)}, {int64(2), int64(1)}, {int64(3), int64(1)}}},
+			query:   "SELECT * FROM task ORDER BY `status` ASC, `int_id` ASC LIMIT ?,?[0 3]",
+			ids:     []int64{1, 2},
+			next:    cursorOf(cursorNext, cursorRow{IntId: 2, Status: 1}),
+		},
+		{
+			name:    "next page with tie",
+			cursor:  cursorOf(cursorNext, cursorRow{IntId: 2, Status: 1}),
+			results: [][][]driver.Value{{{int64(3), int64(1)}}},
+			query:   "SELECT * FROM task WHERE (((status>?) OR (status=? AND int_id>?))) ORDER BY `status` ASC, `int_id` ASC LIMIT ?,?[1 1 2 0 3]",
+			ids:     []int64{3},
+			prev:    cursorOf(cursorPrev, cursorRow{IntId: 3, Status: 1}),
+		},
+		{
+			name:    "prev page with tie",
+			cursor:  cursorOf(cursorPrev, cursorRow{IntId: 3, Status: 1}),
+			results: [][][]driver.Value{{{int64(2), int64(1)}, {int64(1), int64(0)}}},
+			query:   "SELECT * FROM task WHERE (((status<?) OR (status=? AND int_id<?))) ORDER BY `status` DESC, `int_id` DESC LIMIT ?,?[1 1 3 0 3]",
+			ids:     []int64{1, 2},
+			next:    cursorOf(cursorNext, cursorRow{IntId: 2, Status: 1}),
+		},
+		{
+			name:    "empty next page",
+			cursor:  cursorOf(cursorNext, cursorRow{IntId: 3, Status: 1}),
+			results: [][][]driver.Value{{}},
+			query:   "SELECT * FROM task WHERE (((status>?) OR (status=? AND int_id>?))) ORDER BY `status` ASC, `int_id` ASC LIMIT ?,?[1 1 3 0 3]",
+		},
+		{
+			name:    "empty prev page",
+			cursor:  cursorOf(cursorPrev, cursorRow{IntId: 1, Status: 0}),
+			results: [][][]driver.Value{{}},
+			query:   "SELECT * FROM task WHERE (((status<?) OR (status=? AND int_id<?))) ORDER BY `status` DESC, `int_id` DESC LIMIT ?,?[0 0 1 0 3]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{columns: []string{"int_id", "status"}, results: tt.results}
+			c := &core{db: sql.OpenDB(d), table: "task", primaryKey: "int_id"}
+
+			var rows []cursorRow
+			p, err := c.CursorPaginate(context.Background(), tt.cursor, 2, &rows, nil, Asc("status"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{tt.query}; !reflect.DeepEqual(d.queries, want) {
+				t.Errorf("CursorPaginate() queries = %q", d.queries)
+			}
+			var ids []int64
+			for _, row := range rows {
+				ids = append(ids, row.IntId)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("CursorPaginate() rows = %v", ids)
+			}
+			want := CursorPaginator{PerPage: 2, NextCursor: tt.next, PrevCursor: tt.prev, HasNextPage: tt.next != "", HasPrevPage: tt.prev != ""}
+			if *p != want {
+				t.Errorf("CursorPaginate() = %+v, want %+v", *p, want)
+			}
+		})
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"go-artisan/pkg/sqlx"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/didi/gendry/builder"
 	"github.com/didi/gendry/scanner"
@@ -18,7 +22,10 @@ func init() {
 const ScannerTag = "db"
 
 var (
-	defaultPerPage uint = 15
+	defaultPerPage    uint = 15
+	defaultPrimaryKey      = "id"
+
+	errNoSoftDelete = errors.New("model: soft delete column not set")
 )
 
 type (
@@ -31,10 +38,22 @@ type (
 		Find(ctx context.Context, entity interface{}, conditions map[string]interface{}, fields ...string) error
 		Insert(ctx context.Context, data map[string]interface{}) (res sql.Result, err error)
 		Inserts(ctx context.Context, data ...map[string]interface{}) (res sql.Result, err error)
+		Upsert(ctx context.Context, data []map[string]interface{}, updateColumns ...string) (res sql.Result, err error)
+		InsertIgnore(ctx context.Context, data ...map[string]interface{}) (res sql.Result, err error)
+		Replace(ctx context.Context, data ...map[string]interface{}) (res sql.Result, err error)
 		Count(ctx context.Context, conditions map[string]interface{}) (res int64, err error)
 		Delete(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error)
 		Update(ctx context.Context, val map[string]interface{}, conditions map[string]interface{}) (res sql.Result, err error)
 		Pagination(ctx context.Context, page int64, perPage uint, entity interface{}, conditions map[string]interface{}) (paginator *Paginator, err error)
+		CursorPaginate(ctx context.Context, cursor string, perPage uint, entity interface{}, conditions map[string]interface{}, orders ...OrderBy) (paginator *CursorPaginator, err error)
+		Each(ctx context.Context, entity interface{}, conditions map[string]interface{}, fn func(row interface{}) error) error
+		Chunk(ctx context.Context, entity interface{}, conditions map[string]interface{}, size uint, fn func(batch interface{}) error) error
+		// WithTrashed 查询包含已软删除的记录
+		WithTrashed() IModel
+		// OnlyTrashed 只查询已软删除的记录
+		OnlyTrashed() IModel
+		Restore(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error)
+		ForceDelete(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error)
 		Table() string
 	}
 
@@ -50,9 +69,15 @@ type (
 	}
 
 	core struct {
-		db      session
-		table   string
-		perPage uint
+		db         session
+		table      string
+		perPage    uint
+		primaryKey string
+		softDelete string // 软删除字段
+		trashed    int    // 软删除查询范围
+		createdAt  string // 创建时间字段
+		updatedAt  string // 更新时间字段
+		clock      func() time.Time
 	}
 
 	modelTx struct {
@@ -83,14 +108,22 @@ func SetPerPage(perPage uint) Option {
 	}
 }
 
+// 设置主键，默认为id
+func SetPrimaryKey(key string) Option {
+	return func(m *model) {
+		m.primaryKey = key
+	}
+}
+
 // NewModel
 func New(db *sql.DB, table string, opts ...Option) Model {
 	m := &model{
 		db: db,
 		core: &core{
-			db:      db,
-			table:   table,
-			perPage: defaultPerPage,
+			db:         db,
+			table:      table,
+			perPage:    defaultPerPage,
+			primaryKey: defaultPrimaryKey,
 		},
 	}
 	for _, opt := range opts {
@@ -101,13 +134,11 @@ func New(db *sql.DB, table string, opts ...Option) Model {
 
 // 实例化事务对象
 func (m model) TX(tx *sql.Tx) ModelTx {
+	c := *m.core
+	c.db = tx
 	return &modelTx{
-		db: tx,
-		core: &core{
-			db:      tx,
-			table:   m.table,
-			perPage: m.perPage,
-		},
+		db:   tx,
+		core: &c,
 	}
 }
 
@@ -123,7 +154,7 @@ func (m modelTx) DB() *sql.Tx {
 
 // Find
 func (c *core) Find(ctx context.Context, entity interface{}, conditions map[string]interface{}, fields ...string) error {
-	cond, vals, err := builder.BuildSelect(c.table, conditions, fields)
+	cond, vals, err := builder.BuildSelect(c.table, c.scope(conditions), fields)
 	if err != nil {
 		return err
 	}
@@ -145,16 +176,88 @@ func (c *core) Inserts(ctx context.Context, data ...map[string]interface{}) (res
 	if len(data) == 0 {
 		return nil, errors.New("insert data is empty")
 	}
+	cond, vals, err := builder.BuildInsert(c.table, c.insertTimestamps(data))
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// Upsert 插入，唯一键冲突时更新updateColumns，
+// 元素为字段名时更新为插入的值，包含=时作为原始表达式，如 count = count + VALUES(count)，
+// updateColumns为空时更新创建时间以外所有插入的字段
+func (c *core) Upsert(ctx context.Context, data []map[string]interface{}, updateColumns ...string) (res sql.Result, err error) {
+	if len(data) == 0 {
+		return nil, errors.New("insert data is empty")
+	}
+	data = c.insertTimestamps(data)
 	cond, vals, err := builder.BuildInsert(c.table, data)
 	if err != nil {
 		return nil, err
 	}
+
+	if len(updateColumns) == 0 {
+		for column := range data[0] {
+			if column != c.createdAt {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+		sort.Strings(updateColumns)
+	} else if c.updatedAt != "" && !hasUpdateColumn(updateColumns, c.updatedAt) {
+		updateColumns = append(updateColumns, c.updatedAt)
+	}
+	sets := make([]string, 0, len(updateColumns))
+	for _, column := range updateColumns {
+		if strings.Contains(column, "=") {
+			sets = append(sets, column)
+		} else {
+			sets = append(sets, fmt.Sprintf("`%s`=VALUES(`%s`)", column, column))
+		}
+	}
+	cond = fmt.Sprintf("%s ON DUPLICATE KEY UPDATE %s", cond, strings.Join(sets, ","))
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// updateColumns中是否已更新column
+func hasUpdateColumn(updateColumns []string, column string) bool {
+	for _, s := range updateColumns {
+		if s == column {
+			return true
+		}
+		if i := strings.Index(s, "="); i >= 0 && strings.Trim(strings.TrimSpace(s[:i]), "`") == column {
+			return true
+		}
+	}
+	return false
+}
+
+// InsertIgnore 插入，忽略唯一键冲突的记录
+func (c *core) InsertIgnore(ctx context.Context, data ...map[string]interface{}) (res sql.Result, err error) {
+	if len(data) == 0 {
+		return nil, errors.New("insert data is empty")
+	}
+	cond, vals, err := builder.BuildInsertIgnore(c.table, c.insertTimestamps(data))
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// Replace 插入，唯一键冲突时删除旧记录后插入
+func (c *core) Replace(ctx context.Context, data ...map[string]interface{}) (res sql.Result, err error) {
+	if len(data) == 0 {
+		return nil, errors.New("insert data is empty")
+	}
+	cond, vals, err := builder.BuildReplaceInsert(c.table, c.insertTimestamps(data))
+	if err != nil {
+		return nil, err
+	}
 	return c.db.ExecContext(ctx, cond, vals...)
 }
 
 // Count
 func (c *core) Count(ctx context.Context, conditions map[string]interface{}) (res int64, err error) {
-	cond, vals, err := builder.BuildSelect(c.table, conditions, []string{"count(*)"})
+	cond, vals, err := builder.BuildSelect(c.table, c.scope(conditions), []string{"count(*)"})
 	if err != nil {
 		return res, err
 	}
@@ -175,8 +278,11 @@ func (c *core) Count(ctx context.Context, conditions map[string]interface{}) (re
 	return res, nil
 }
 
-// Delete
+// Delete 设置软删除字段时只写入删除时间
 func (c *core) Delete(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error) {
+	if c.softDelete != "" {
+		return c.softDeleteRows(ctx, conditions)
+	}
 	cond, vals, err := builder.BuildDelete(c.table, conditions)
 	if err != nil {
 		return nil, err
@@ -186,7 +292,7 @@ func (c *core) Delete(ctx context.Context, conditions map[string]interface{}) (r
 
 // Update
 func (c *core) Update(ctx context.Context, val map[string]interface{}, conditions map[string]interface{}) (res sql.Result, err error) {
-	cond, vals, err := builder.BuildUpdate(c.table, conditions, val)
+	cond, vals, err := builder.BuildUpdate(c.table, c.scope(conditions), c.updateTimestamps(val))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// 记录执行的sql
+type recordSession struct {
+	query string
+	args  []interface{}
+}
+
+func (s *recordSession) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	s.query, s.args = query, args
+	return nil, nil
+}
+
+func (s *recordSession) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	s.query, s.args = query, args
+	return nil, sql.ErrConnDone
+}
+
+func TestUpsert(t *testing.T) {
+	db := &recordSession{}
+	c := &core{db: db, table: "counter"}
+
+	_, err := c.Upsert(context.Background(), []map[string]interface{}{
+		{"name": "a", "count": 1},
+	}, "name", "count = count + VALUES(count)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO counter (count,name) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),count = count + VALUES(count)"
+	if db.query != want || len(db.args) != 2 {
+		t.Errorf("Upsert() query = %s, args = %v", db.query, db.args)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	db := &recordSession{}
+	c := &core{db: db, table: "task", softDelete: "deleted_at"}
+
+	if _, err := c.Delete(ctx, map[string]interface{}{"int_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "UPDATE task SET deleted_at=? WHERE (int_id=? AND deleted_at IS NULL)"; db.query != want {
+		t.Errorf("Delete() query = %s", db.query)
+	}
+
+	c.OnlyTrashed().Count(ctx, map[string]interface{}{"int_id": 1})
+	if want := "SELECT count(*) FROM task WHERE (int_id=? AND deleted_at IS NOT NULL)"; db.query != want {
+		t.Errorf("OnlyTrashed().Count() query = %s", db.query)
+	}
+
+	c.WithTrashed().Find(ctx, &[]cursorRow{}, map[string]interface{}{"int_id": 1})
+	if want := "SELECT * FROM task WHERE (int_id=?)"; db.query != want {
+		t.Errorf("WithTrashed().Find() query = %s", db.query)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 3, 1, 8, 0, 0, 0, time.UTC)
+	db := &recordSession{}
+	m := New(nil, "task", SetTimestamps("created_at", "updated_at"), SetClock(func() time.Time {
+		return now
+	})).(*model)
+	m.core.db = db
+
+	if _, err := m.Insert(ctx, map[string]interface{}{"name": "a", "created_at": time.Time{}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.args) != 3 || db.args[0] != now || db.args[2] != now {
+		t.Errorf("Insert() query = %s, args = %v", db.query, db.args)
+	}
+
+	if _, err := m.Upsert(ctx, []map[string]interface{}{{"name": "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "INSERT INTO task (created_at,name,updated_at) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`updated_at`=VALUES(`updated_at`)"; db.query != want {
+		t.Errorf("Upsert() query = %s", db.query)
+	}
+
+	if _, err := m.Update(ctx, map[string]interface{}{"name": "b"}, map[string]interface{}{"int_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.args) != 3 || db.args[1] != now {
+		t.Errorf("Update() query = %s, args = %v", db.query, db.args)
+	}
+
+	// 软删除与恢复同样更新updated_at
+	m.core.softDelete = "deleted_at"
+	if _, err := m.Restore(ctx, map[string]interface{}{"int_id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "UPDATE task SET deleted_at=?,updated_at=? WHERE (int_id=? AND deleted_at IS NOT NULL)"; db.query != want || db.args[1] != now {
+		t.Errorf("Restore() query = %s, args = %v", db.query, db.args)
+	}
+}
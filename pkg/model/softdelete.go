@@ -0,0 +1,98 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/didi/gendry/builder"
+)
+
+// 软删除查询范围
+const (
+	withoutTrashed = iota // 只查询未删除的记录
+	withTrashed           // 查询所有记录
+	onlyTrashed           // 只查询已删除的记录
+)
+
+// 设置软删除字段，如deleted_at，Delete改为写入删除时间，查询自动过滤已删除的记录
+func SetSoftDelete(column string) Option {
+	return func(m *model) {
+		m.softDelete = column
+	}
+}
+
+// WithTrashed 查询包含已删除的记录
+func (c *core) WithTrashed() IModel {
+	return c.withScope(withTrashed)
+}
+
+// OnlyTrashed 只查询已删除的记录
+func (c *core) OnlyTrashed() IModel {
+	return c.withScope(onlyTrashed)
+}
+
+func (c *core) withScope(scope int) IModel {
+	scoped := *c
+	scoped.trashed = scope
+	return &scoped
+}
+
+// Restore 恢复已删除的记录，未设置软删除字段时不执行
+func (c *core) Restore(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error) {
+	if c.softDelete == "" {
+		return nil, errNoSoftDelete
+	}
+	cond, vals, err := builder.BuildUpdate(c.table, c.scopeConditions(conditions, onlyTrashed), c.updateTimestamps(map[string]interface{}{
+		c.softDelete: nil,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// ForceDelete 物理删除，包括已软删除的记录
+func (c *core) ForceDelete(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error) {
+	cond, vals, err := builder.BuildDelete(c.table, conditions)
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// 软删除，写入删除时间
+func (c *core) softDeleteRows(ctx context.Context, conditions map[string]interface{}) (res sql.Result, err error) {
+	cond, vals, err := builder.BuildUpdate(c.table, c.scope(conditions), c.updateTimestamps(map[string]interface{}{
+		c.softDelete: c.now(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ExecContext(ctx, cond, vals...)
+}
+
+// 按当前查询范围增加软删除条件
+func (c *core) scope(conditions map[string]interface{}) map[string]interface{} {
+	return c.scopeConditions(conditions, c.trashed)
+}
+
+// 返回增加软删除条件后的副本，调用方已指定软删除字段条件时不修改
+func (c *core) scopeConditions(conditions map[string]interface{}, scope int) map[string]interface{} {
+	if c.softDelete == "" || scope == withTrashed {
+		return conditions
+	}
+	if _, ok := conditions[c.softDelete]; ok {
+		return conditions
+	}
+
+	res := make(map[string]interface{}, len(conditions)+1)
+	for k, v := range conditions {
+		res[k] = v
+	}
+	if scope == onlyTrashed {
+		res[c.softDelete] = builder.IsNotNull
+	} else {
+		res[c.softDelete] = builder.IsNull
+	}
+	return res
+}
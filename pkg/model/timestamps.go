@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+)
+
+// 设置自动维护的创建时间与更新时间字段，如created_at、updated_at，为空时不维护
+func SetTimestamps(createdAt, updatedAt string) Option {
+	return func(m *model) {
+		m.createdAt = createdAt
+		m.updatedAt = updatedAt
+	}
+}
+
+// 设置时间来源，默认为time.Now，用于测试时固定时间
+func SetClock(now func() time.Time) Option {
+	return func(m *model) {
+		m.clock = now
+	}
+}
+
+func (c *core) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+// 插入的记录未指定时间或为零值时写入当前时间，返回副本
+func (c *core) insertTimestamps(data []map[string]interface{}) []map[string]interface{} {
+	if c.createdAt == "" && c.updatedAt == "" {
+		return data
+	}
+
+	now := c.now()
+	res := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		copied := make(map[string]interface{}, len(row)+2)
+		for k, v := range row {
+			copied[k] = v
+		}
+		fillTimestamp(copied, c.createdAt, now)
+		fillTimestamp(copied, c.updatedAt, now)
+		res[i] = copied
+	}
+	return res
+}
+
+// 更新的字段未指定更新时间时写入当前时间，返回副本
+func (c *core) updateTimestamps(val map[string]interface{}) map[string]interface{} {
+	if c.updatedAt == "" {
+		return val
+	}
+	if _, ok := val[c.updatedAt]; ok {
+		return val
+	}
+
+	res := make(map[string]interface{}, len(val)+1)
+	for k, v := range val {
+		res[k] = v
+	}
+	res[c.updatedAt] = c.now()
+	return res
+}
+
+func fillTimestamp(row map[string]interface{}, column string, now time.Time) {
+	if column == "" {
+		return
+	}
+	switch v := row[column].(type) {
+	case nil:
+		row[column] = now
+	case time.Time:
+		if v.IsZero() {
+			row[column] = now
+		}
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			row[column] = now
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"go-artisan/pkg/cache"
+)
+
+// 单次SCAN与DEL的key数量
+const cacheScanSize = 500
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and evict model cache entries in redis",
+	Long: `Inspect and evict model cache entries in redis.
+
+Redis connection is read from the cache section of .tools.yaml:
+
+  cache:
+    addr: 127.0.0.1:6379
+    password: ""
+    db: 0
+    name: task`,
+}
+
+// cacheGetCmd represents the cache get command
+var cacheGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Decode and print a cache entry",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		rdb := newCacheClient()
+		defer rdb.Close()
+
+		key := args[0]
+		data, err := rdb.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return fmt.Errorf("key %s not found", key)
+		}
+		if err != nil {
+			return err
+		}
+		ttl, err := rdb.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		info, err := cache.Inspect(data)
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "key:         %s\n", key)
+		fmt.Fprintf(out, "ttl:         %s\n", formatTTL(ttl))
+		fmt.Fprintf(out, "size:        %d\n", info.Size)
+		fmt.Fprintf(out, "placeholder: %t\n", info.Placeholder)
+		if info.Placeholder {
+			return nil
+		}
+		fmt.Fprintf(out, "codec:       %s\n", info.Codec)
+		if info.Compression != "" {
+			fmt.Fprintf(out, "compression: %s\n", info.Compression)
+		}
+		if !info.SoftExpireAt.IsZero() {
+			fmt.Fprintf(out, "soft expire: %s\n", info.SoftExpireAt.Format(time.RFC3339))
+		}
+		if !info.ExpireAt.IsZero() {
+			fmt.Fprintf(out, "expire:      %s\n", info.ExpireAt.Format(time.RFC3339))
+		}
+		if err != nil {
+			return fmt.Errorf("decode value: %v", err)
+		}
+		if info.Value == nil {
+			return nil
+		}
+		value, err := json.MarshalIndent(info.Value, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "value:\n%s\n", value)
+		return nil
+	},
+}
+
+// cacheDelCmd represents the cache del command
+var cacheDelCmd = &cobra.Command{
+	Use:   "del [key...]",
+	Short: "Delete cache entries and notify instances to clear local cache",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pattern, _ := cmd.Flags().GetString("pattern")
+		if len(args) == 0 && pattern == "" {
+			return errors.New("requires keys or --pattern")
+		}
+
+		ctx := context.Background()
+		rdb := newCacheClient()
+		defer rdb.Close()
+
+		name := viper.GetString("cache.name")
+		deleted := 0
+		del := func(keys []string) error {
+			if len(keys) == 0 {
+				return nil
+			}
+			n, err := rdb.Del(ctx, keys...).Result()
+			if err != nil {
+				return err
+			}
+			deleted += int(n)
+			return cache.PublishDeleteLocalCache(ctx, rdb, name, keys...)
+		}
+
+		if err := del(args); err != nil {
+			return err
+		}
+		if pattern != "" {
+			var cursor uint64
+			for {
+				keys, next, err := rdb.Scan(ctx, cursor, pattern, cacheScanSize).Result()
+				if err != nil {
+					return err
+				}
+				if err := del(keys); err != nil {
+					return err
+				}
+				if cursor = next; cursor == 0 {
+					break
+				}
+			}
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "deleted %d keys\n", deleted)
+		return nil
+	},
+}
+
+// cacheStatsCmd represents the cache stats command
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print redis memory and hit statistics",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		rdb := newCacheClient()
+		defer rdb.Close()
+
+		fields := make(map[string]string)
+		for _, section := range []string{"memory", "stats"} {
+			info, err := rdb.Info(ctx, section).Result()
+			if err != nil {
+				return err
+			}
+			parseRedisInfo(info, fields)
+		}
+		size, err := rdb.DBSize(ctx).Result()
+		if err != nil {
+			return err
+		}
+
+		hits, _ := strconv.ParseUint(fields["keyspace_hits"], 10, 64)
+		misses, _ := strconv.ParseUint(fields["keyspace_misses"], 10, 64)
+		var ratio float64
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "keys:             %d\n", size)
+		fmt.Fprintf(out, "used memory:      %s\n", fields["used_memory_human"])
+		fmt.Fprintf(out, "peak memory:      %s\n", fields["used_memory_peak_human"])
+		fmt.Fprintf(out, "max memory:       %s\n", fields["maxmemory_human"])
+		fmt.Fprintf(out, "keyspace hits:    %d\n", hits)
+		fmt.Fprintf(out, "keyspace misses:  %d\n", misses)
+		fmt.Fprintf(out, "hit ratio:        %.2f%%\n", ratio*100)
+		fmt.Fprintf(out, "evicted keys:     %s\n", fields["evicted_keys"])
+		fmt.Fprintf(out, "expired keys:     %s\n", fields["expired_keys"])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheGetCmd, cacheDelCmd, cacheStatsCmd)
+
+	cacheCmd.PersistentFlags().String("addr", "127.0.0.1:6379", "redis address")
+	cacheCmd.PersistentFlags().String("password", "", "redis password")
+	cacheCmd.PersistentFlags().Int("db", 0, "redis database")
+	cacheCmd.PersistentFlags().String("name", "", "cache name used to clear local cache, empty for all caches")
+	cobra.CheckErr(viper.BindPFlag("cache.addr", cacheCmd.PersistentFlags().Lookup("addr")))
+	cobra.CheckErr(viper.BindPFlag("cache.password", cacheCmd.PersistentFlags().Lookup("password")))
+	cobra.CheckErr(viper.BindPFlag("cache.db", cacheCmd.PersistentFlags().Lookup("db")))
+	cobra.CheckErr(viper.BindPFlag("cache.name", cacheCmd.PersistentFlags().Lookup("name")))
+
+	cacheDelCmd.Flags().String("pattern", "", "delete keys matching the glob pattern")
+}
+
+func newCacheClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     viper.GetString("cache.addr"),
+		Password: viper.GetString("cache.password"),
+		DB:       viper.GetInt("cache.db"),
+	})
+}
+
+func formatTTL(ttl time.Duration) string {
+	switch {
+	case ttl == -1:
+		return "no expiration"
+	case ttl < 0:
+		return "expired"
+	default:
+		return ttl.String()
+	}
+}
+
+// 解析INFO命令返回的key:value
+func parseRedisInfo(info string, fields map[string]string) {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+}